@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	appPersistent bool
+	appProject    string
+	appVolumes    bool
+	appYes        bool
+)
+
+var appCmd = &cobra.Command{
+	Use:   "app",
+	Short: "Create, delete and rename apps",
+	Long: `Create, delete and rename apps.
+
+When run inside the workspace of the instance, or from its parent directory, the
+app configuration files are updated as well.`,
+}
+
+var appCreateCmd = &cobra.Command{
+	Use:     "create <name>",
+	Short:   "Create an app",
+	Example: "letgofur app create my-app\nletgofur app create my-db --persistent\nletgofur app create checkout --project shop/backend",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		// older CapRover versions have no projects, their apps stay at the top
+		projects, err := client.GetProjects()
+		if err != nil && appProject != "" {
+			return fmt.Errorf("error getting projects: %w", err)
+		}
+		var project crapi.ProjectDefinition
+		if appProject != "" {
+			var ok bool
+			if project, ok = crapi.FindProject(projects, appProject); !ok {
+				return fmt.Errorf("project '%s' not found", appProject)
+			}
+		}
+
+		if err := client.CreateApp(appName, appPersistent); err != nil {
+			return fmt.Errorf("error creating app '%s': %w", appName, err)
+		}
+		fmt.Printf("App '%s' created.\n", appName)
+
+		if project.ID != "" {
+			err := client.PatchApp(appName, func(req *crapi.UpdateAppRequest) error {
+				req.ProjectID = project.ID
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("error moving app '%s' to project '%s': %w", appName, appProject, err)
+			}
+			fmt.Printf("App '%s' moved to project '%s'.\n", appName, appProject)
+		}
+
+		dir, ok := currentWorkspace(client)
+		if !ok {
+			return nil
+		}
+		if file, exists := findAppConfig(dir, appName); exists {
+			fmt.Printf("Workspace already has a config for app '%s' at '%s', apply it to configure the app.\n", appName, file)
+			return nil
+		}
+
+		app, err := client.GetAppDetailFor(appName)
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+		config, files, err := workspace.ExportFiles(app, "")
+		if err != nil {
+			return err
+		}
+
+		configFile, path, err := appConfigFile(dir, projects, app)
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			config.Project = &path
+			if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+				return fmt.Errorf("error creating project directory: %w", err)
+			}
+		}
+		if err := workspace.Save(configFile, config); err != nil {
+			return err
+		}
+		if err := workspace.SaveFiles(configFile, files); err != nil {
+			return err
+		}
+		fmt.Printf("Generated config for app '%s' at '%s'\n", appName, configFile)
+		return nil
+	},
+}
+
+var appDeleteCmd = &cobra.Command{
+	Use:   "delete <name...> | -l <selector>",
+	Short: "Delete apps",
+	Long: `Delete one or more apps, after asking for confirmation.
+
+With --volumes, the persistent volumes of the apps are deleted too, except the
+ones still used by other apps.`,
+	Example: "letgofur app delete my-app\nletgofur app delete old-api old-worker --volumes --yes\nletgofur app delete -l env=preview --yes",
+	Aliases: []string{"rm"},
+	Args:    cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		args, err := selectApps(client, args)
+		if err != nil {
+			return err
+		}
+
+		list, err := client.GetAppDetails()
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+
+		deleting := map[string]bool{}
+		for _, name := range args {
+			deleting[name] = true
+		}
+
+		found := map[string]bool{}
+		volumes := map[string][]string{}
+		shared := map[string]bool{}
+		for _, app := range list.Data.AppDefinitions {
+			found[app.AppName] = true
+			for _, v := range app.Volumes {
+				if v.VolumeName == "" {
+					continue
+				}
+				if deleting[app.AppName] {
+					volumes[app.AppName] = append(volumes[app.AppName], v.VolumeName)
+				} else {
+					shared[v.VolumeName] = true
+				}
+			}
+		}
+		for _, name := range args {
+			if !found[name] {
+				return fmt.Errorf("app %s not found", name)
+			}
+		}
+
+		var deletedVolumes []string
+		if appVolumes {
+			for name, names := range volumes {
+				var kept []string
+				for _, v := range names {
+					if shared[v] {
+						fmt.Fprintf(os.Stderr, "Keeping volume '%s', it is used by other apps.\n", v)
+						continue
+					}
+					kept = append(kept, v)
+				}
+				volumes[name] = kept
+				deletedVolumes = append(deletedVolumes, kept...)
+			}
+		}
+
+		if !appYes {
+			question := fmt.Sprintf("Delete app(s) %s", strings.Join(args, ", "))
+			if len(deletedVolumes) > 0 {
+				question += fmt.Sprintf(" and volume(s) %s", strings.Join(deletedVolumes, ", "))
+			}
+			if !confirm(cmd, question+"? This can't be undone.") {
+				return errors.New("aborted")
+			}
+		}
+
+		dir, inWorkspace := currentWorkspace(client)
+
+		var errs []error
+		for _, name := range args {
+			var remove []string
+			if appVolumes {
+				remove = volumes[name]
+			}
+
+			if err := client.RemoveAppWithVolumes(name, remove); err != nil {
+				errs = append(errs, fmt.Errorf("error deleting app '%s': %w", name, err))
+				continue
+			}
+			fmt.Printf("App '%s' deleted.\n", name)
+
+			if !inWorkspace {
+				continue
+			}
+			file, ok := findAppConfig(dir, name)
+			if !ok {
+				continue
+			}
+			config, err := workspace.Load(file)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := os.Remove(file); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			fmt.Printf("Removed '%s' from the workspace.\n", file)
+
+			// the NGINX template and pre-deploy script of the app
+			removed, err := removeAppFiles(dir, config)
+			for _, path := range removed {
+				fmt.Printf("Removed '%s' from the workspace.\n", path)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	},
+}
+
+var appRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename an app",
+	Long: `Rename an app. Its settings and versions are kept.
+
+The internal hostname of the app, srv-captain--<name>, changes with the name:
+update the apps connecting to it.`,
+	Example: "letgofur app rename my-app my-api",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		oldName, newName := args[0], args[1]
+
+		if err := client.RenameApp(oldName, newName); err != nil {
+			return fmt.Errorf("error renaming app '%s': %w", oldName, err)
+		}
+		fmt.Printf("App '%s' renamed to '%s'.\n", oldName, newName)
+
+		dir, ok := currentWorkspace(client)
+		if !ok {
+			return nil
+		}
+		file, ok := findAppConfig(dir, oldName)
+		if !ok {
+			return nil
+		}
+
+		if err := workspace.SetValue(file, newName, "AppName"); err != nil {
+			return err
+		}
+
+		// a config named after the app follows the new name, into the
+		// directory of the app's project
+		ext := filepath.Ext(file)
+		if filepath.Base(file) == oldName+ext {
+			app, err := client.GetAppDetailFor(newName)
+			if err != nil {
+				return fmt.Errorf("error getting app details: %w", err)
+			}
+			// older CapRover versions have no projects, their apps stay at the top
+			projects, _ := client.GetProjects()
+			renamed, _, err := appConfigFile(dir, projects, app)
+			if err != nil {
+				return err
+			}
+			renamed = strings.TrimSuffix(renamed, ".yml") + ext
+			if err := os.MkdirAll(filepath.Dir(renamed), 0755); err != nil {
+				return fmt.Errorf("error creating project directory: %w", err)
+			}
+			if err := os.Rename(file, renamed); err != nil {
+				return err
+			}
+			if err := moveAppFiles(file, renamed); err != nil {
+				return err
+			}
+			file = renamed
+		}
+
+		fmt.Printf("Updated '%s'.\n", file)
+		return nil
+	},
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from the
+// command input. Anything but yes, including no input at all, means no.
+func confirm(cmd *cobra.Command, question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	appCreateCmd.Flags().BoolVar(&appPersistent, "persistent", false, "Create an app with persistent data, which can have volumes")
+	appCreateCmd.Flags().StringVar(&appProject, "project", "", "Project to create the app in, as a path like shop/backend")
+	appDeleteCmd.Flags().BoolVar(&appVolumes, "volumes", false, "Also delete the persistent volumes of the apps")
+	appDeleteCmd.Flags().BoolVarP(&appYes, "yes", "y", false, "Don't ask for confirmation")
+
+	appCmd.AddCommand(appCreateCmd, appDeleteCmd, appRenameCmd)
+	rootCmd.AddCommand(appCmd)
+}
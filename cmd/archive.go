@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore or .dockerignore file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreRules decides which files are left out of a source archive. As in
+// git, the last matching rule wins.
+type ignoreRules []ignoreRule
+
+// loadIgnoreRules reads the .gitignore and .dockerignore files at the root of
+// dir. .gitignore patterns without a slash match at any depth, while
+// .dockerignore patterns are always relative to the root, as in Docker.
+func loadIgnoreRules(dir string) (ignoreRules, error) {
+	var rules ignoreRules
+
+	for _, f := range []struct {
+		name     string
+		anchored bool
+	}{
+		{".gitignore", false},
+		{".dockerignore", true},
+	} {
+		file, err := os.Open(filepath.Join(dir, f.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(scanner.Text(), f.anchored); ok {
+				rules = append(rules, rule)
+			}
+		}
+		file.Close()
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", f.name, err)
+		}
+	}
+
+	return rules, nil
+}
+
+func parseIgnoreRule(line string, anchored bool) (ignoreRule, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{anchored: anchored}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+	}
+
+	rule.pattern = strings.TrimPrefix(path.Clean("/"+line), "/")
+	if rule.pattern == "" {
+		return ignoreRule{}, false
+	}
+
+	return rule, true
+}
+
+// ignored reports whether the slash separated path rel, relative to the root
+// of the archive, is excluded.
+func (rules ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		if !rule.anchored {
+			target = path.Base(rel)
+		}
+
+		if matchGlob(strings.Split(rule.pattern, "/"), strings.Split(target, "/")) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// matchGlob matches path segments against pattern segments, where "**"
+// matches any number of segments.
+func matchGlob(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlob(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	ok, err := path.Match(pattern[0], segments[0])
+	if err != nil || !ok {
+		return false
+	}
+
+	return matchGlob(pattern[1:], segments[1:])
+}
+
+// sourceArchive is a tar archive of an app's source written to a temporary
+// file. Close removes the file.
+type sourceArchive struct {
+	*os.File
+	Size  int64
+	Files int
+}
+
+func (a *sourceArchive) Close() error {
+	a.File.Close()
+	return os.Remove(a.Name())
+}
+
+// createSourceArchive tars dir, leaving out .git and everything matched by
+// its .gitignore and .dockerignore files. The captain-definition file at
+// definitionPath, relative to dir, is always included.
+func createSourceArchive(dir string, definitionPath string) (*sourceArchive, error) {
+	rules, err := loadIgnoreRules(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	definitionPath = path.Clean(filepath.ToSlash(definitionPath))
+
+	file, err := os.CreateTemp("", "letgofur-source-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("error creating source archive: %w", err)
+	}
+	archive := &sourceArchive{File: file}
+
+	tw := tar.NewWriter(file)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		if rel != definitionPath && rules.ignored(rel, d.IsDir()) {
+			if d.IsDir() && !strings.HasPrefix(definitionPath, rel+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return addToArchive(tw, p, rel, d, archive)
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		archive.Size, err = file.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("error creating source archive: %w", err)
+	}
+
+	return archive, nil
+}
+
+func addToArchive(tw *tar.Writer, p string, rel string, d fs.DirEntry, archive *sourceArchive) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	} else if !info.Mode().IsRegular() && !info.IsDir() {
+		// sockets, devices and the like can't be part of a build context
+		return nil
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = rel
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return err
+	}
+
+	archive.Files++
+	return nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pararang/letgofur/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	authUser     string
+	authPassword string
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the HTTP basic auth of an app",
+	Long: `Manage the HTTP basic auth protecting an app.
+
+CapRover asks for the user and password before serving any request to the app.`,
+}
+
+var authSetCmd = &cobra.Command{
+	Use:   "set <app>",
+	Short: "Protect an app with HTTP basic auth",
+	Long: `Protect an app with HTTP basic auth, or change its user or password.
+
+The password is a secret reference, env:NAME or file:PATH, so it doesn't end
+up in the shell history. Without --password it is read from stdin.`,
+	Example: "letgofur auth set my-app --user admin --password env:ADMIN_PASSWORD\necho \"$PASSWORD\" | letgofur auth set my-app --user admin",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		if authUser == "" {
+			return fmt.Errorf("--user is required")
+		}
+
+		ref := workspace.SecretRef(authPassword)
+		var password string
+		if ref == "" {
+			fmt.Fprint(os.Stderr, "Password: ")
+			line, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+			password = line
+			// the password isn't kept anywhere the workspace can read it
+			ref = workspace.Redacted
+		} else {
+			var err error
+			if password, err = ref.Resolve("."); err != nil {
+				return fmt.Errorf("invalid --password: %w", err)
+			}
+		}
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			return fmt.Errorf("the password can't be empty")
+		}
+
+		if err := client.SetHTTPAuth(appName, authUser, password); err != nil {
+			return fmt.Errorf("error setting the HTTP auth of app '%s': %w", appName, err)
+		}
+		fmt.Printf("App '%s' is now protected by HTTP basic auth for user '%s'.\n", appName, authUser)
+
+		file, ok := workspaceConfig(client, appName)
+		if !ok {
+			return nil
+		}
+		return setWorkspaceValue(file, workspace.HTTPAuthConfig{User: authUser, Password: relativeSecretRef(ref, filepath.Dir(file))}, "HTTPAuth")
+	},
+}
+
+var authClearCmd = &cobra.Command{
+	Use:     "clear <app>",
+	Short:   "Remove the HTTP basic auth of an app",
+	Example: "letgofur auth clear my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		if err := client.ClearHTTPAuth(appName); err != nil {
+			return fmt.Errorf("error removing the HTTP auth of app '%s': %w", appName, err)
+		}
+		fmt.Printf("App '%s' is no longer protected by HTTP basic auth.\n", appName)
+
+		// an empty user removes the auth when the workspace is applied
+		return syncWorkspace(client, appName, workspace.HTTPAuthConfig{}, "HTTPAuth")
+	},
+}
+
+// relativeSecretRef rewrites a file reference relative to the current
+// directory to one relative to dir, the directory of the configuration file
+// it is written to, as the workspace resolves them. Other references are
+// returned as they are.
+func relativeSecretRef(ref workspace.SecretRef, dir string) workspace.SecretRef {
+	path, ok := strings.CutPrefix(string(ref), "file:")
+	if !ok || filepath.IsAbs(path) || path == "~" || strings.HasPrefix(path, "~/") {
+		return ref
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ref
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return ref
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return ref
+	}
+
+	return workspace.SecretRef("file:" + filepath.ToSlash(rel))
+}
+
+func init() {
+	authSetCmd.Flags().StringVar(&authUser, "user", "", "User to ask for")
+	authSetCmd.Flags().StringVar(&authPassword, "password", "", "Password reference, env:NAME or file:PATH; read from stdin when empty")
+
+	authCmd.AddCommand(authSetCmd, authClearCmd)
+	rootCmd.AddCommand(authCmd)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	buildWait     bool
+	buildTimeout  time.Duration
+	buildParallel int
+)
+
+var buildCmd = &cobra.Command{
+	Use:   "build <app...> | -l <selector>",
+	Short: "Force a build of apps from their git repository",
+	Long: `Force a build of one or more apps from their git repository, as a push to the
+repository would.
+
+The push webhook token of every app is looked up, so the apps must have a git
+repository set up. With --wait, the command follows the builds and prints their
+logs, prefixed with the app name when several apps are built.`,
+	Example: "letgofur build my-app --wait\nletgofur build api worker web --wait --parallel 2\nletgofur build -l team=payments --wait",
+	Args:    cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		if buildParallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+		args, err := selectApps(client, args)
+		if err != nil {
+			return err
+		}
+
+		// one app list for all the webhook tokens, instead of one per app
+		apps, err := client.GetAppDetails()
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+		tokens := make(map[string]string, len(apps.Data.AppDefinitions))
+		for _, app := range apps.Data.AppDefinitions {
+			tokens[app.AppName] = app.AppPushWebhook.PushWebhookToken
+		}
+
+		printer := newLogPrinter(args)
+		errs := make([]error, len(args))
+		slots := make(chan struct{}, buildParallel)
+
+		var wg sync.WaitGroup
+		for i, appName := range args {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				errs[i] = buildApp(client, appName, tokens[appName], printer)
+			}()
+		}
+		wg.Wait()
+
+		failed := 0
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+			failed++
+			errs[i] = fmt.Errorf("app '%s': %w", args[i], err)
+			// with --wait, the whole log was already printed
+			if !buildWait {
+				printBuildFailure(err)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d builds failed:\n%w", failed, len(args), errors.Join(errs...))
+		}
+
+		return nil
+	},
+}
+
+// buildApp triggers a build of an app with its push webhook token and, with
+// --wait, follows it.
+func buildApp(client Client, appName string, token string, printer *logPrinter) error {
+	if token == "" {
+		return fmt.Errorf("no push webhook, set up a git repository for the app first")
+	}
+
+	// lines of earlier builds must not be shown as part of this one
+	before, err := client.GetBuildStatus(appName)
+	if err != nil {
+		return fmt.Errorf("error getting build status: %w", err)
+	}
+
+	if err := client.ForceBuild(token); err != nil {
+		return fmt.Errorf("error triggering the build: %w", err)
+	}
+	printer.printLine(appName, fmt.Sprintf("Build of app '%s' triggered", appName))
+
+	if !buildWait {
+		return nil
+	}
+
+	_, err = client.WatchBuild(appName, crapi.BuildWatchOptions{
+		Interval: buildPollInterval,
+		Timeout:  buildTimeout,
+		Seen:     before.Logs.Lines,
+		OnLine: func(line string) {
+			printer.printLine(appName, line)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	printer.printLine(appName, fmt.Sprintf("App '%s' built successfully!", appName))
+	return nil
+}
+
+func init() {
+	buildCmd.Flags().BoolVarP(&buildWait, "wait", "w", false, "Wait for the builds to finish, printing their logs")
+	buildCmd.Flags().DurationVar(&buildTimeout, "timeout", 30*time.Minute, "How long to wait for each build with --wait")
+	buildCmd.Flags().IntVarP(&buildParallel, "parallel", "p", 4, "How many apps to build at the same time")
+
+	rootCmd.AddCommand(buildCmd)
+}
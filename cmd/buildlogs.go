@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	buildLogsFollow  bool
+	buildLogsTimeout time.Duration
+)
+
+var buildLogsCmd = &cobra.Command{
+	Use:   "build-logs <app>",
+	Short: "Show the build logs of an app",
+	Long: `Show the build logs of an app.
+
+With --follow, new lines are printed as they come until the running build finishes.
+The command exits with an error when the build failed.`,
+	Example: "letgofur build-logs my-app\nletgofur build-logs my-app --follow",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		if !buildLogsFollow {
+			status, err := client.GetBuildStatus(appName)
+			if err != nil {
+				return fmt.Errorf("error getting build logs: %w", err)
+			}
+
+			for _, line := range status.Logs.Lines {
+				printBuildLogLine(line)
+			}
+
+			if status.IsBuildFailed {
+				return &crapi.BuildFailedError{AppName: appName}
+			}
+			return nil
+		}
+
+		_, err := client.WatchBuild(appName, crapi.BuildWatchOptions{
+			Interval: buildPollInterval,
+			Timeout:  buildLogsTimeout,
+			OnLine:   printBuildLogLine,
+		})
+		return err
+	},
+}
+
+func printBuildLogLine(line string) {
+	fmt.Println(line)
+}
+
+// printBuildFailure prints the end of the build log carried by a
+// *crapi.BuildFailedError, for builds whose log wasn't followed.
+func printBuildFailure(err error) {
+	var failed *crapi.BuildFailedError
+	if !errors.As(err, &failed) || len(failed.LogTail) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Build of app '%s' failed, last lines of the build log:\n", failed.AppName)
+	for _, line := range failed.LogTail {
+		fmt.Fprintf(os.Stderr, "  %s\n", line)
+	}
+}
+
+func init() {
+	buildLogsCmd.Flags().BoolVarP(&buildLogsFollow, "follow", "f", false, "Follow the running build until it finishes")
+	buildLogsCmd.Flags().DurationVar(&buildLogsTimeout, "timeout", 30*time.Minute, "How long to follow the build")
+
+	rootCmd.AddCommand(buildLogsCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/workspace"
+	"github.com/spf13/cobra"
+)
+
+// Client is the part of the CapRover API used by the letgofur commands.
+// *crapi.Caprover implements it.
+type Client interface {
+	workspace.Client
+
+	BaseURL() string
+	Hostname() string
+	GetAppDetails() (crapi.ListAppResponse, error)
+	GetAppDetailFor(appName string) (crapi.AppDefinition, error)
+
+	PatchApp(appName string, patch crapi.PatchFunc) error
+	CreateApp(appName string, hasPersistentData bool) error
+	RemoveAppWithVolumes(appName string, volumes []string) error
+	RenameApp(oldAppName string, newAppName string) error
+
+	RestartApp(appName string) error
+	UpdateInstanceCount(appName string, instances int) error
+	UpdateContainerHTTPPort(appName string, newPort int) error
+	EnableWebsocketSupport(appName string) error
+	DisableWebsocketSupport(appName string) error
+	EnableForceHTTPS(appName string) error
+	DisableForceHTTPS(appName string) error
+	UpdateResourceLimits(appName string, limits crapi.ResourceLimits) error
+	SetHTTPAuth(appName string, user string, password string) error
+	ClearHTTPAuth(appName string) error
+
+	AddCustomDomain(appName string, domain string) error
+	RemoveCustomDomain(appName string, domain string) error
+	EnableBaseDomainSSL(appName string) error
+	EnableCustomDomainSSL(appName string, domain string) error
+	SetRedirectDomain(appName string, domain string) error
+
+	CreateProject(name string, parentProjectID string, description string) error
+	DeleteProjects(projectIDs ...string) error
+
+	ForceBuild(token string) error
+	PushWebhookURL(token string) string
+	DeploySourceArchive(appName string, archive io.ReadSeeker, size int64, progress crapi.UploadProgress) error
+	GetBuildStatus(appName string) (crapi.AppBuildLogData, error)
+	WatchBuild(appName string, opts crapi.BuildWatchOptions) (crapi.AppBuildLogData, error)
+	DeployImage(appName string, imageName string) error
+	WaitForVersion(appName string, afterVersion int, interval time.Duration, timeout time.Duration) (crapi.AppDefinition, error)
+
+	GetAppLogs(appName string) (string, error)
+}
+
+var _ Client = (*crapi.Caprover)(nil)
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying client. Commands executed with
+// this context use client instead of connecting with --host and --passwd.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// clientFrom returns the client injected into the context of cmd, or nil.
+func clientFrom(cmd *cobra.Command) Client {
+	ctx := cmd.Context()
+	if ctx == nil {
+		return nil
+	}
+
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
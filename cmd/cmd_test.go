@@ -0,0 +1,1607 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/crapi/crapitest"
+	"github.com/pararang/letgofur/workspace"
+	"gopkg.in/yaml.v3"
+)
+
+func newTestServer(t *testing.T, apps ...crapi.AppDefinition) *crapitest.Server {
+	t.Helper()
+
+	srv := crapitest.NewServer("", apps...)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// execute runs letgofur against srv with the given arguments and returns what
+// it printed to stdout.
+func execute(t *testing.T, srv *crapitest.Server, args ...string) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	rootCmd.SetArgs(append([]string{"--host", srv.URL, "--passwd", srv.Password}, args...))
+	rootCmd.SetErr(io.Discard)
+	err = ExecuteContext(context.Background())
+
+	w.Close()
+	return <-out, err
+}
+
+// chdir switches to a fresh temporary directory for the duration of the test.
+func chdir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return dir
+}
+
+// captureStderr returns what fn writes to stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	fn()
+
+	w.Close()
+	return <-out
+}
+
+// fastBuildPolls polls builds every millisecond for the duration of the test.
+func fastBuildPolls(t *testing.T) {
+	t.Helper()
+
+	interval := buildPollInterval
+	buildPollInterval = time.Millisecond
+	t.Cleanup(func() { buildPollInterval = interval })
+}
+
+func writeConfig(t *testing.T, config workspace.AppConfig) string {
+	t.Helper()
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), config.AppName+".yml")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func int64p(v int64) *int64 { return &v }
+
+func intp(v int) *int { return &v }
+
+func TestWrongPassword(t *testing.T) {
+	srv := newTestServer(t)
+
+	rootCmd.SetArgs([]string{"--host", srv.URL, "--passwd", "wrong", "ls"})
+	rootCmd.SetErr(io.Discard)
+	if err := ExecuteContext(context.Background()); err == nil {
+		t.Fatal("expected an error for a wrong password")
+	}
+}
+
+func TestLs(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "api"},
+		crapi.AppDefinition{AppName: "web"},
+	)
+
+	out, err := execute(t, srv, "ls")
+	if err != nil {
+		t.Fatalf("ls: %v", err)
+	}
+
+	for _, want := range []string{"- api\n", "- web\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("ls output %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestLsServerError(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"})
+	srv.Inject(crapitest.Fault{Path: crapi.URLAppListPath, HTTPStatus: 502})
+
+	if _, err := execute(t, srv, "ls"); err == nil {
+		t.Fatal("expected ls to fail on a 502")
+	}
+}
+
+func TestInitWorkspace(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "api", InstanceCount: 2,
+			ServiceUpdateOverride: "TaskTemplate:\n  Resources:\n    Limits:\n      MemoryBytes: 268435456\n"},
+		crapi.AppDefinition{AppName: "web", InstanceCount: 1},
+	)
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "127-0-0-1", "api.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config workspace.AppConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.AppName != "api" || config.Instances == nil || *config.Instances != 2 {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if mem := config.Resources.Limits.MemoryBytes; mem == nil || *mem != 268435456 {
+		t.Errorf("memory limit not exported: %v", mem)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "127-0-0-1", "web.yml")); err != nil {
+		t.Errorf("web.yml not generated: %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "api", InstanceCount: 1,
+			EnvVars:               []crapi.EnvVarInformation{{Key: "MODE", Value: "prod"}},
+			ServiceUpdateOverride: "TaskTemplate:\n  Placement:\n    Constraints: [node.role==worker]\n"},
+	)
+
+	file := writeConfig(t, workspace.AppConfig{
+		AppName:   "api",
+		Instances: intp(3),
+		Resources: workspace.Resources{Limits: workspace.Resource{MemoryBytes: int64p(512 * crapi.ResourceOneMb)}},
+	})
+
+	if _, err := execute(t, srv, "apply", file); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	app, _ := srv.App("api")
+	if app.InstanceCount != 3 {
+		t.Errorf("instance count is %d, want 3", app.InstanceCount)
+	}
+	if !strings.Contains(app.ServiceUpdateOverride, "MemoryBytes: 536870912") {
+		t.Errorf("resource limits not applied: %q", app.ServiceUpdateOverride)
+	}
+	if !strings.Contains(app.ServiceUpdateOverride, "node.role==worker") {
+		t.Errorf("the other override settings were not preserved: %q", app.ServiceUpdateOverride)
+	}
+	if len(app.EnvVars) != 1 || app.EnvVars[0].Value != "prod" {
+		t.Errorf("env vars were not preserved: %+v", app.EnvVars)
+	}
+}
+
+func TestApplyManyFilesListsAppsOnce(t *testing.T) {
+	var apps []crapi.AppDefinition
+	var files []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		apps = append(apps, crapi.AppDefinition{AppName: name, InstanceCount: 1})
+		files = append(files, writeConfig(t, workspace.AppConfig{AppName: name, Instances: intp(2)}))
+	}
+	srv := newTestServer(t, apps...)
+
+	if _, err := execute(t, srv, append([]string{"apply"}, files...)...); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	for _, app := range srv.Apps() {
+		if app.InstanceCount != 2 {
+			t.Errorf("%s has %d instances, want 2", app.AppName, app.InstanceCount)
+		}
+	}
+	if got := srv.Requests(crapi.URLAppListPath); got != 1 {
+		t.Errorf("app list fetched %d times, want 1", got)
+	}
+}
+
+func TestApplyRenewsExpiredToken(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", InstanceCount: 1})
+	srv.Inject(crapitest.Fault{Path: crapi.URLUpdateAppPath, Times: 1, ExpireToken: true})
+
+	file := writeConfig(t, workspace.AppConfig{AppName: "api", Instances: intp(2)})
+	if _, err := execute(t, srv, "apply", file); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if app, _ := srv.App("api"); app.InstanceCount != 2 {
+		t.Errorf("instance count is %d, want 2", app.InstanceCount)
+	}
+	if got := srv.Logins(); got != 2 {
+		t.Errorf("got %d logins, want 2", got)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", InstanceCount: 1})
+
+	if _, err := execute(t, srv, "apply", writeConfig(t, workspace.AppConfig{AppName: "missing", Instances: intp(2)})); err == nil {
+		t.Error("expected an error for an unknown app")
+	}
+
+	if _, err := execute(t, srv, "apply", filepath.Join(t.TempDir(), "nope.yml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	srv.Inject(crapitest.Fault{Path: crapi.URLUpdateAppPath, Status: crapi.StatusErrorGeneric, Description: "boom"})
+	_, err := execute(t, srv, "apply", writeConfig(t, workspace.AppConfig{AppName: "api", Instances: intp(2)}))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the CapRover error description, got %v", err)
+	}
+}
+
+// stubClient serves a fixed app list without any CapRover server.
+type stubClient struct {
+	Client
+	apps []crapi.AppDefinition
+}
+
+func (s stubClient) BaseURL() string  { return "https://captain.stub.test" }
+func (s stubClient) Hostname() string { return "captain.stub.test" }
+
+func (s stubClient) GetProjects() ([]crapi.ProjectDefinition, error) { return nil, nil }
+
+func (s stubClient) GetAppDetails() (crapi.ListAppResponse, error) {
+	var rsp crapi.ListAppResponse
+	rsp.Status = crapi.StatusOK
+	rsp.Data.AppDefinitions = s.apps
+	return rsp, nil
+}
+
+func TestInjectedClient(t *testing.T) {
+	client := stubClient{apps: []crapi.AppDefinition{{AppName: "stubbed"}}}
+	dir := chdir(t)
+
+	rootCmd.SetArgs([]string{"init"})
+	if err := ExecuteContext(WithClient(context.Background(), client)); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "captain-stub-test", "stubbed.yml")); err != nil {
+		t.Errorf("stubbed.yml not generated: %v", err)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeploySourceDirectory(t *testing.T) {
+	fastBuildPolls(t)
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"})
+	srv.BuildPolls = 2
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"captain-definition":       `{"schemaVersion": 2, "dockerfilePath": "./Dockerfile"}`,
+		"Dockerfile":               "FROM alpine",
+		"main.go":                  "package main",
+		".gitignore":               "*.log\n/build/\n",
+		".dockerignore":            "docs\n",
+		"debug.log":                "noise",
+		"sub/trace.log":            "noise",
+		"build/out":                "binary",
+		"docs/README.md":           "docs",
+		".git/HEAD":                "ref: refs/heads/main",
+		"sub/keep.txt":             "keep",
+		"sub/docs/nested-kept.txt": "kept, .dockerignore is anchored",
+	})
+
+	if _, err := execute(t, srv, "deploy", "api", dir); err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+
+	uploads := srv.Uploads("api")
+	if len(uploads) != 1 {
+		t.Fatalf("got %d uploads, want 1", len(uploads))
+	}
+
+	got := map[string]bool{}
+	for _, name := range uploads[0] {
+		got[name] = true
+	}
+	for _, want := range []string{"captain-definition", "Dockerfile", "main.go", "sub/keep.txt", "sub/docs/nested-kept.txt"} {
+		if !got[want] {
+			t.Errorf("%s missing from the archive %v", want, uploads[0])
+		}
+	}
+	for _, unwanted := range []string{"debug.log", "sub/trace.log", "build/out", "docs/README.md", ".git/HEAD"} {
+		if got[unwanted] {
+			t.Errorf("%s should have been ignored", unwanted)
+		}
+	}
+
+	if app, _ := srv.App("api"); len(app.Versions) != 1 {
+		t.Errorf("got %d versions after deploy, want 1", len(app.Versions))
+	}
+}
+
+func TestDeployFailures(t *testing.T) {
+	fastBuildPolls(t)
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"})
+
+	if _, err := execute(t, srv, "deploy", "api", t.TempDir()); err == nil {
+		t.Error("expected an error without a captain-definition")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"captain-definition": `{"schemaVersion": 2}`})
+	srv.FailBuilds("api", true)
+
+	_, err := execute(t, srv, "deploy", "api", dir)
+	if !errors.Is(err, crapi.ErrBuildFailed) {
+		t.Errorf("expected a failed build, got %v", err)
+	}
+}
+
+func TestDeployImage(t *testing.T) {
+	fastBuildPolls(t)
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", InstanceCount: 2})
+	srv.BuildPolls = 3
+
+	out, err := execute(t, srv, "deploy", "api", "--image", "registry.example.com/api:1.4.2")
+	if err != nil {
+		t.Fatalf("deploy --image: %v", err)
+	}
+
+	app, _ := srv.App("api")
+	latest, ok := crapi.LatestVersion(app)
+	if !ok || latest.DeployedImageName != "registry.example.com/api:1.4.2" || app.DeployedVersion != latest.Version {
+		t.Errorf("image not deployed: %+v", app.Versions)
+	}
+	if !strings.Contains(out, "running version 0 (image registry.example.com/api:1.4.2) with 2 instance(s)") {
+		t.Errorf("final state not reported: %q", out)
+	}
+
+	if _, err := execute(t, srv, "deploy", "api", "some/dir", "--image", "x:1"); err == nil {
+		t.Error("expected an error when mixing a directory and --image")
+	}
+}
+
+func TestBuildLogs(t *testing.T) {
+	fastBuildPolls(t)
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"})
+	srv.SetBuildLogs("api", "step 1/2", "step 2/2")
+
+	out, err := execute(t, srv, "build-logs", "api", "--follow")
+	if err != nil {
+		t.Fatalf("build-logs: %v", err)
+	}
+	if strings.Count(out, "step 1/2\n") != 1 || strings.Count(out, "step 2/2\n") != 1 {
+		t.Errorf("each line should be printed once: %q", out)
+	}
+
+	srv.FailBuilds("api", true)
+	srv.BuildPolls = 2
+	if _, err := execute(t, srv, "deploy", "api", "--image", "api:broken"); !errors.Is(err, crapi.ErrBuildFailed) {
+		t.Errorf("expected a failed build, got %v", err)
+	}
+
+	if _, err := execute(t, srv, "build-logs", "api"); !errors.Is(err, crapi.ErrBuildFailed) {
+		t.Errorf("build-logs should fail after a failed build, got %v", err)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"}, crapi.AppDefinition{AppName: "worker"})
+	frame := "\x01\x00\x00\x00\x00\x00\x00\x2a"
+	srv.SetAppLogs("api", frame+"2024-05-01T10:00:00.000000001Z started\n"+
+		frame+"2024-05-01T10:00:02Z ERROR boom\n"+
+		frame+"    at main.go:12\n"+
+		frame+"2024-05-01T10:00:04Z ready\n")
+	srv.SetAppLogs("worker", "\x02\x00\x00\x00\x00\x00\x00\x10"+"2024-05-01T10:00:03Z ERROR queue\n")
+
+	out, err := execute(t, srv, "logs", "api", "--tail", "3")
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if want := "ERROR boom\n    at main.go:12\nready\n"; out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+
+	// a line without a time stays after the line before it
+	out, err = execute(t, srv, "logs", "api", "worker")
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	want := "api    | started\napi    | ERROR boom\napi    |     at main.go:12\nworker | ERROR queue\napi    | ready\n"
+	if out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+
+	out, err = execute(t, srv, "logs", "api", "worker", "--grep", "ERROR")
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if want := "api    | ERROR boom\nworker | ERROR queue\n"; out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+
+	if _, err := execute(t, srv, "logs", "api", "--grep", "("); err == nil {
+		t.Error("an invalid --grep pattern should fail")
+	}
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	fastBuildPolls(t)
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"})
+
+	if _, err := execute(t, srv, "rollback", "api"); err == nil {
+		t.Error("rollback of an app without versions should fail")
+	}
+
+	for _, image := range []string{"api:1", "api:2", "api:3"} {
+		if _, err := execute(t, srv, "deploy", "api", "--image", image); err != nil {
+			t.Fatalf("deploy %s: %v", image, err)
+		}
+	}
+
+	out, err := execute(t, srv, "history", "api")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if !strings.Contains(out, "*  2 ") || strings.Index(out, "api:3") > strings.Index(out, "api:1") {
+		t.Errorf("history should list the newest version first and mark the running one: %q", out)
+	}
+
+	if _, err := execute(t, srv, "rollback", "api"); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	app, _ := srv.App("api")
+	if latest, _ := crapi.LatestVersion(app); latest.DeployedImageName != "api:2" || app.DeployedVersion != 3 {
+		t.Errorf("expected version 3 running api:2, got %+v", app.Versions)
+	}
+
+	// a second rollback goes further back instead of returning to api:3
+	if _, err := execute(t, srv, "rollback", "api"); err != nil {
+		t.Fatalf("second rollback: %v", err)
+	}
+	app, _ = srv.App("api")
+	if latest, _ := crapi.LatestVersion(app); latest.DeployedImageName != "api:1" || app.DeployedVersion != 4 {
+		t.Errorf("expected version 4 running api:1, got %+v", app.Versions)
+	}
+	if _, err := execute(t, srv, "rollback", "api"); err == nil {
+		t.Error("rollback past the first version should fail")
+	}
+
+	if _, err := execute(t, srv, "rollback", "api", "--to", "2"); err != nil {
+		t.Fatalf("rollback --to: %v", err)
+	}
+	app, _ = srv.App("api")
+	if latest, _ := crapi.LatestVersion(app); latest.DeployedImageName != "api:3" {
+		t.Errorf("expected api:3 to be redeployed, got %+v", latest)
+	}
+
+	if _, err := execute(t, srv, "rollback", "api", "--to", "42"); err == nil {
+		t.Error("rollback to an unknown version should fail")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	fastBuildPolls(t)
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"}, crapi.AppDefinition{AppName: "worker"}, crapi.AppDefinition{AppName: "web"})
+
+	out, err := execute(t, srv, "build", "api", "worker", "--wait", "--parallel", "1")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	for _, name := range []string{"api", "worker"} {
+		app, _ := srv.App(name)
+		if len(app.Versions) != 1 {
+			t.Errorf("%s should have been built once, got %+v", name, app.Versions)
+		}
+		if !strings.Contains(out, "App '"+name+"' built successfully!") {
+			t.Errorf("%s build not reported: %q", name, out)
+		}
+	}
+	if !strings.Contains(out, "worker | Deployed version 0\n") {
+		t.Errorf("build logs should be prefixed with the app name: %q", out)
+	}
+
+	srv.FailBuilds("web", true)
+	listed := srv.Requests(crapi.URLAppListPath)
+	stderr := captureStderr(t, func() {
+		out, err = execute(t, srv, "build", "api", "web", "--wait")
+	})
+	if !errors.Is(err, crapi.ErrBuildFailed) || !strings.Contains(err.Error(), "1 of 2 builds failed") {
+		t.Errorf("expected the web build to fail, got %v", err)
+	}
+	if app, _ := srv.App("api"); len(app.Versions) != 2 {
+		t.Errorf("a failed build must not stop the others: %+v", app.Versions)
+	}
+	// the followed log isn't repeated
+	if strings.Count(out, "Build has failed!") != 1 || strings.Contains(stderr, "last lines of the build log") {
+		t.Errorf("the end of a followed build log should be printed once, got %q and %q", out, stderr)
+	}
+	if got := srv.Requests(crapi.URLAppListPath) - listed; got != 1 {
+		t.Errorf("app list fetched %d times for two builds, want 1", got)
+	}
+}
+
+func TestGitSettings(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"})
+
+	if _, err := execute(t, srv, "git-info", "api"); err == nil {
+		t.Error("git-info should fail for an app without a repository")
+	}
+
+	t.Setenv("API_GIT_PASSWORD", "s3cret\n")
+	file := writeConfig(t, workspace.AppConfig{
+		AppName: "api",
+		Git: &workspace.GitConfig{
+			Repo:     "github.com/acme/api",
+			Branch:   "main",
+			User:     "deploy",
+			Password: "env:API_GIT_PASSWORD",
+		},
+	})
+
+	out, err := execute(t, srv, "apply", file)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if strings.Contains(out, "s3cret") || !strings.Contains(out, "Git.Password: none -> set") {
+		t.Errorf("secrets must not be printed: %q", out)
+	}
+
+	app, _ := srv.App("api")
+	repo := app.AppPushWebhook.RepoInfo
+	if repo.Repo != "github.com/acme/api" || repo.Branch != "main" || repo.Password != "s3cret" {
+		t.Errorf("repository not applied: %+v", repo)
+	}
+
+	out, err = execute(t, srv, "git-info", "api")
+	if err != nil {
+		t.Fatalf("git-info: %v", err)
+	}
+	if !strings.Contains(out, srv.URL+"/api/v2/user/apps/webhooks/triggerbuild?namespace=captain&token=webhook-api") {
+		t.Errorf("webhook URL not printed: %q", out)
+	}
+
+	dir := chdir(t)
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	exported, err := workspace.Load(filepath.Join(dir, "127-0-0-1", "api.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.Git == nil || exported.Git.Repo != "github.com/acme/api" || exported.Git.Password != workspace.Redacted {
+		t.Errorf("git settings not exported with redacted secrets: %+v", exported.Git)
+	}
+
+	// the exported file keeps the current secrets
+	if out, err := execute(t, srv, "apply", filepath.Join(dir, "127-0-0-1", "api.yml")); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("exported config should be up to date, got %q, %v", out, err)
+	}
+
+	bad := writeConfig(t, workspace.AppConfig{AppName: "api", Git: &workspace.GitConfig{Repo: "r", Password: "hunter2"}})
+	if _, err := execute(t, srv, "apply", bad); err == nil {
+		t.Error("inline secrets should be rejected")
+	}
+}
+
+func TestDeployToken(t *testing.T) {
+	fastBuildPolls(t)
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api"})
+
+	out, err := execute(t, srv, "deploy-token", "enable", "api")
+	if err != nil {
+		t.Fatalf("deploy-token enable: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	token := lines[len(lines)-1]
+	if app, _ := srv.App("api"); !app.AppDeployTokenConfig.Enabled || app.AppDeployTokenConfig.AppDeployToken != token {
+		t.Fatalf("token %q not enabled: %+v", token, app.AppDeployTokenConfig)
+	}
+
+	if out, _ := execute(t, srv, "deploy-token", "enable", "api"); !strings.HasSuffix(out, token+"\n") {
+		t.Errorf("enabling again should keep the token: %q", out)
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"captain-definition": `{"schemaVersion": 2}`})
+
+	logins := srv.Logins()
+	if _, err := execute(t, srv, "deploy", "api", dir, "--app-token", token); err != nil {
+		t.Fatalf("deploy --app-token: %v", err)
+	}
+	if _, err := execute(t, srv, "deploy", "api", "--image", "api:2", "--app-token", token); err != nil {
+		t.Fatalf("deploy --image --app-token: %v", err)
+	}
+	if srv.Logins() != logins {
+		t.Error("deploying with an app token must not log in")
+	}
+	if app, _ := srv.App("api"); len(app.Versions) != 2 {
+		t.Errorf("got %d versions, want 2", len(app.Versions))
+	}
+
+	if _, err := execute(t, srv, "deploy-token", "rotate", "api"); err != nil {
+		t.Fatalf("deploy-token rotate: %v", err)
+	}
+	if _, err := execute(t, srv, "deploy", "api", dir, "--app-token", token); err == nil {
+		t.Error("the rotated token should be rejected")
+	}
+
+	if _, err := execute(t, srv, "deploy-token", "disable", "api"); err != nil {
+		t.Fatalf("deploy-token disable: %v", err)
+	}
+	if out, _ := execute(t, srv, "deploy-token", "show", "api"); !strings.Contains(out, "disabled") {
+		t.Errorf("token should be disabled: %q", out)
+	}
+}
+
+func TestAppLifecycle(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "db", HasPersistentData: true, Volumes: []crapi.VolumeInformation{
+			{ContainerPath: "/data", VolumeName: "db-data"},
+			{ContainerPath: "/shared", VolumeName: "shared"},
+		}},
+		crapi.AppDefinition{AppName: "backup", Volumes: []crapi.VolumeInformation{{ContainerPath: "/shared", VolumeName: "shared"}}},
+	)
+	dir := filepath.Join(chdir(t), "127-0-0-1")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, srv, "app", "create", "api"); err != nil {
+		t.Fatalf("app create: %v", err)
+	}
+	if _, ok := srv.App("api"); !ok {
+		t.Fatal("app not created")
+	}
+	file := filepath.Join(dir, "api.yml")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("workspace config not generated: %v", err)
+	}
+
+	if err := os.WriteFile(file, append([]byte("# the public API\n"), data...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, srv, "app", "rename", "api", "public-api"); err != nil {
+		t.Fatalf("app rename: %v", err)
+	}
+	if _, ok := srv.App("public-api"); !ok {
+		t.Error("app not renamed")
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "public-api.yml"))
+	if err != nil {
+		t.Fatalf("config file not renamed: %v", err)
+	}
+	if !strings.Contains(string(data), "# the public API") || !strings.Contains(string(data), "AppName: public-api") {
+		t.Errorf("config not updated in place: %s", data)
+	}
+
+	rootCmd.SetIn(strings.NewReader("n\n"))
+	defer rootCmd.SetIn(nil)
+	if _, err := execute(t, srv, "app", "delete", "public-api"); err == nil {
+		t.Error("delete should be aborted without confirmation")
+	}
+	if _, ok := srv.App("public-api"); !ok {
+		t.Error("app deleted without confirmation")
+	}
+
+	if _, err := execute(t, srv, "app", "delete", "public-api", "db", "--volumes", "--yes"); err != nil {
+		t.Fatalf("app delete: %v", err)
+	}
+	if apps := srv.Apps(); len(apps) != 1 {
+		t.Errorf("expected only backup to be left, got %+v", apps)
+	}
+	if got := srv.DeletedVolumes(); len(got) != 1 || got[0] != "db-data" {
+		t.Errorf("only the unshared volume should be deleted, got %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "public-api.yml")); !os.IsNotExist(err) {
+		t.Errorf("config file should be removed, got %v", err)
+	}
+}
+
+func TestAppDeleteRemovesReferencedFiles(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "api", CustomNginxConfig: "server {}\n", PreDeployFunction: "var preDeployFunction = function (captainAppObj, dockerUpdateObject) {\n  return Promise.resolve(dockerUpdateObject);\n};\n"},
+		crapi.AppDefinition{AppName: "web"},
+	)
+	dir := filepath.Join(chdir(t), "127-0-0-1")
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	nginx := filepath.Join(dir, "nginx", "api.conf")
+	script := filepath.Join(dir, "predeploy", "api.js")
+	for _, path := range []string{nginx, script} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s not exported: %v", path, err)
+		}
+	}
+
+	// a template shared with another app stays
+	shared := "AppName: web\nNginx: ./nginx/api.conf\n"
+	if err := os.WriteFile(filepath.Join(dir, "web.yml"), []byte(shared), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, srv, "app", "delete", "api", "--yes"); err != nil {
+		t.Fatalf("app delete: %v", err)
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Errorf("pre-deploy script should be removed, got %v", err)
+	}
+	if _, err := os.Stat(nginx); err != nil {
+		t.Errorf("template still used by web was removed: %v", err)
+	}
+
+	if _, err := execute(t, srv, "app", "delete", "web", "--yes"); err != nil {
+		t.Fatalf("app delete: %v", err)
+	}
+	if _, err := os.Stat(nginx); !os.IsNotExist(err) {
+		t.Errorf("template should be removed with its last app, got %v", err)
+	}
+}
+
+func TestAppCreateAndRenameInProject(t *testing.T) {
+	srv := newTestServer(t)
+	shop := srv.AddProject("shop", "")
+	srv.AddProject("backend", shop)
+	dir := filepath.Join(chdir(t), "127-0-0-1")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, srv, "app", "create", "api", "--project", "nope"); err == nil {
+		t.Error("expected an error for an unknown project")
+	}
+	if _, ok := srv.App("api"); ok {
+		t.Error("app created despite the unknown project")
+	}
+
+	if _, err := execute(t, srv, "app", "create", "api", "--project", "shop/backend"); err != nil {
+		t.Fatalf("app create: %v", err)
+	}
+	app, _ := srv.App("api")
+	if crapi.ProjectPath(srv.Projects(), app.ProjectID) != "shop/backend" {
+		t.Errorf("app not moved to the project: %q", app.ProjectID)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "shop", "backend", "api.yml"))
+	if err != nil {
+		t.Fatalf("config not written to the project directory: %v", err)
+	}
+	if !strings.Contains(string(data), "Project: shop/backend") {
+		t.Errorf("project not set in the config: %s", data)
+	}
+
+	// a config left at the top, from before the app was moved, follows it
+	// into the project directory with the files it references
+	if err := os.Remove(filepath.Join(dir, "shop", "backend", "api.yml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "api.yml"), []byte("AppName: api\nNginx: ./nginx/api.conf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "nginx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nginx", "api.conf"), []byte("server {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, srv, "app", "rename", "api", "checkout"); err != nil {
+		t.Fatalf("app rename: %v", err)
+	}
+	renamed := filepath.Join(dir, "shop", "backend", "checkout.yml")
+	if _, err := os.Stat(renamed); err != nil {
+		t.Fatalf("config not moved to the project directory: %v", err)
+	}
+	config, err := workspace.Load(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AppName != "checkout" {
+		t.Errorf("app name not updated: %q", config.AppName)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shop", "backend", "nginx", "api.conf")); err != nil {
+		t.Errorf("referenced template not moved with the config: %v", err)
+	}
+}
+
+func TestSettingsCommandsUpdateWorkspace(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", InstanceCount: 1,
+		EnvVars:               []crapi.EnvVarInformation{{Key: "MODE", Value: "prod"}},
+		ServiceUpdateOverride: `{"TaskTemplate":{"Placement":{"Constraints":["node.role==worker"]},"Resources":{"Limits":{"NanoCPUs":250000000}}}}`})
+	dir := filepath.Join(chdir(t), "127-0-0-1")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "api.yml")
+	if err := os.WriteFile(file, []byte("# keep me\nAppName: api\nInstances: 1 # peak traffic\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"restart", "api"},
+		{"scale", "api", "3"},
+		{"set-port", "api", "3000"},
+		{"websocket", "api", "on"},
+		{"force-https", "api", "on"},
+		{"limit", "api", "--memory", "512M", "--cpu", "0.5"},
+	} {
+		if _, err := execute(t, srv, args...); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	app, _ := srv.App("api")
+	if app.InstanceCount != 3 || app.ContainerHTTPPort != 3000 || !app.WebsocketSupport || !app.ForceSsl {
+		t.Errorf("settings not applied: %+v", app)
+	}
+	if !strings.Contains(app.ServiceUpdateOverride, "MemoryBytes: 536870912") || !strings.Contains(app.ServiceUpdateOverride, "NanoCPUs: 500000000") {
+		t.Errorf("limits not applied: %q", app.ServiceUpdateOverride)
+	}
+	if !strings.Contains(app.ServiceUpdateOverride, "node.role==worker") {
+		t.Errorf("the other override settings were not preserved: %q", app.ServiceUpdateOverride)
+	}
+	if len(app.EnvVars) != 1 {
+		t.Errorf("env vars were not preserved: %+v", app.EnvVars)
+	}
+
+	if _, err := execute(t, srv, "limit", "api", "--cpu", "0"); err != nil {
+		t.Fatalf("limit --cpu 0: %v", err)
+	}
+	app, _ = srv.App("api")
+	if strings.Contains(app.ServiceUpdateOverride, "NanoCPUs") || !strings.Contains(app.ServiceUpdateOverride, "MemoryBytes: 536870912") {
+		t.Errorf("only the CPU limit should be removed: %q", app.ServiceUpdateOverride)
+	}
+
+	data, _ := os.ReadFile(file)
+	for _, want := range []string{"# keep me", "Instances: 3 # peak traffic", "ContainerHTTPPort: 3000", "WebsocketSupport: true", "MemoryBytes: 536870912", "NanoCPUs: null"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("workspace file should contain %q:\n%s", want, data)
+		}
+	}
+
+	if out, err := execute(t, srv, "apply", file); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("workspace should match the app, got %q, %v", out, err)
+	}
+
+	if _, err := execute(t, srv, "websocket", "api", "maybe"); err == nil {
+		t.Error("expected an error for an invalid toggle")
+	}
+
+	// scaled to 0 then up again outside letgofur, the workspace has drifted
+	if _, err := execute(t, srv, "scale", "api", "0"); err != nil {
+		t.Fatalf("scale to 0: %v", err)
+	}
+	if data, _ := os.ReadFile(file); !strings.Contains(string(data), "Instances: 0") {
+		t.Errorf("workspace file should be scaled to 0:\n%s", data)
+	}
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateInstanceCount("api", 2); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, srv, "plan", file); err != nil || !strings.Contains(out, "Instances: 2 -> 0") {
+		t.Errorf("plan should report the drift, got %q, %v", out, err)
+	}
+}
+
+func TestNginxTemplate(t *testing.T) {
+	const custom = "server {\n  listen 80;\n  client_max_body_size 50m;\n}\n"
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "api", CustomNginxConfig: custom},
+		crapi.AppDefinition{AppName: "web", CustomNginxConfig: "default template\n"},
+	)
+	srv.DefaultNginxConfig = "default template\n"
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	ws := filepath.Join(dir, "127-0-0-1")
+	template := filepath.Join(ws, "nginx", "api.conf")
+	if data, err := os.ReadFile(template); err != nil || string(data) != custom {
+		t.Fatalf("template not exported: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "nginx", "web.conf")); !os.IsNotExist(err) {
+		t.Error("default templates should not be exported")
+	}
+
+	config := filepath.Join(ws, "api.yml")
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("exported config should be up to date, got %q, %v", out, err)
+	}
+
+	changed := strings.Replace(custom, "50m", "200m", 1)
+	if err := os.WriteFile(template, []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execute(t, srv, "plan", config)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if !strings.Contains(out, "-  client_max_body_size 50m;") || !strings.Contains(out, "+  client_max_body_size 200m;") {
+		t.Errorf("plan should show the template diff: %q", out)
+	}
+	if app, _ := srv.App("api"); app.CustomNginxConfig != custom {
+		t.Error("plan must not change the app")
+	}
+
+	if _, err := execute(t, srv, "apply", config); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if app, _ := srv.App("api"); app.CustomNginxConfig != changed {
+		t.Errorf("template not uploaded: %q", app.CustomNginxConfig)
+	}
+
+	if err := workspace.SetValue(config, workspace.NginxDefault, "Nginx"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, srv, "validate", config); err != nil {
+		t.Errorf("validate: %v", err)
+	}
+	out, err = execute(t, srv, "plan", config)
+	if err != nil || !strings.Contains(out, "Nginx: custom (4 lines) -> default") || !strings.Contains(out, "-  listen 80;") {
+		t.Errorf("plan should show the reset, got %q, %v", out, err)
+	}
+	if _, err := execute(t, srv, "apply", config); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if app, _ := srv.App("api"); app.CustomNginxConfig != "" {
+		t.Errorf("template not reset: %q", app.CustomNginxConfig)
+	}
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("a reset template should be up to date, got %q, %v", out, err)
+	}
+}
+
+func TestHTTPAuth(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", InstanceCount: 2})
+	dir := chdir(t)
+	t.Setenv("API_PASSWORD", "s3cret\n")
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	config := filepath.Join(dir, "127-0-0-1", "api.yml")
+
+	if _, err := execute(t, srv, "auth", "set", "api", "--user", "admin", "--password", "env:API_PASSWORD"); err != nil {
+		t.Fatalf("auth set: %v", err)
+	}
+	app, _ := srv.App("api")
+	if app.HTTPAuth == nil || app.HTTPAuth.User != "admin" || app.HTTPAuth.PasswordHashed != crapitest.HashPassword("s3cret") {
+		t.Fatalf("auth not set: %+v", app.HTTPAuth)
+	}
+	if app.InstanceCount != 2 {
+		t.Errorf("other settings were reset: %+v", app)
+	}
+	if data, _ := os.ReadFile(config); !strings.Contains(string(data), "Password: env:API_PASSWORD") {
+		t.Errorf("workspace should reference the password:\n%s", data)
+	}
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("workspace should match the app, got %q, %v", out, err)
+	}
+
+	rootCmd.SetIn(strings.NewReader("n3w\n"))
+	defer rootCmd.SetIn(nil)
+	if _, err := execute(t, srv, "auth", "set", "api", "--user", "admin"); err != nil {
+		t.Fatalf("auth set from stdin: %v", err)
+	}
+	if app, _ := srv.App("api"); app.HTTPAuth == nil || app.HTTPAuth.PasswordHashed != crapitest.HashPassword("n3w") {
+		t.Errorf("password not changed: %+v", app.HTTPAuth)
+	}
+
+	// a new user with a redacted password keeps the password
+	if err := workspace.SetValue(config, "ops", "HTTPAuth", "User"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := execute(t, srv, "apply", config)
+	if err != nil || !strings.Contains(out, "HTTPAuth.User: admin -> ops") || strings.Contains(out, "n3w") {
+		t.Errorf("apply should change the user only, got %q, %v", out, err)
+	}
+	if app, _ := srv.App("api"); app.HTTPAuth == nil || app.HTTPAuth.User != "ops" || app.HTTPAuth.PasswordHashed != crapitest.HashPassword("n3w") {
+		t.Errorf("user not changed or password lost: %+v", app.HTTPAuth)
+	}
+
+	if _, err := execute(t, srv, "auth", "clear", "api"); err != nil {
+		t.Fatalf("auth clear: %v", err)
+	}
+	if app, _ := srv.App("api"); app.HTTPAuth != nil {
+		t.Errorf("auth not cleared: %+v", app.HTTPAuth)
+	}
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("workspace should match the app, got %q, %v", out, err)
+	}
+
+	// a file path relative to the current directory is written relative to
+	// the configuration file, so the workspace can be committed
+	if err := os.MkdirAll(filepath.Join(dir, "secrets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secrets", "api"), []byte("f1le\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, srv, "auth", "set", "api", "--user", "admin", "--password", "file:secrets/api"); err != nil {
+		t.Fatalf("auth set from a file: %v", err)
+	}
+	if data, _ := os.ReadFile(config); !strings.Contains(string(data), "Password: file:../secrets/api") {
+		t.Errorf("workspace should reference the file relative to the config:\n%s", data)
+	}
+	if app, _ := srv.App("api"); app.HTTPAuth == nil || app.HTTPAuth.PasswordHashed != crapitest.HashPassword("f1le") {
+		t.Errorf("password not set from the file: %+v", app.HTTPAuth)
+	}
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("workspace should match the app, got %q, %v", out, err)
+	}
+
+	if _, err := execute(t, srv, "auth", "set", "api", "--user", "admin", "--password", "hunter2"); err == nil {
+		t.Error("expected an error for an inline password")
+	}
+}
+
+func TestDomains(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", InstanceCount: 2})
+
+	out, err := execute(t, srv, "domain", "add", "api", "api.com", "www.api.com", "--ssl")
+	if err != nil {
+		t.Fatalf("domain add: %v", err)
+	}
+	for _, want := range []string{"api.example.com  no   base domain", "api.com          yes", "www.api.com      yes"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("domain add should print %q:\n%s", want, out)
+		}
+	}
+
+	if _, err := execute(t, srv, "domain", "ssl", "api"); err != nil {
+		t.Fatalf("domain ssl: %v", err)
+	}
+	if _, err := execute(t, srv, "domain", "redirect", "api", "api.com"); err != nil {
+		t.Fatalf("domain redirect: %v", err)
+	}
+	out, err = execute(t, srv, "domain", "ls", "api")
+	if err != nil || !strings.Contains(out, "api.example.com  yes  base domain") || !strings.Contains(out, "api.com          yes  redirect target") {
+		t.Errorf("domain ls should show SSL and the redirect, got %q, %v", out, err)
+	}
+	if app, _ := srv.App("api"); app.RedirectDomain != "api.com" || app.InstanceCount != 2 {
+		t.Errorf("redirect not set or settings lost: %+v", app)
+	}
+
+	if _, err := execute(t, srv, "domain", "redirect", "api", "other.com"); err == nil {
+		t.Error("expected an error redirecting to a domain of another app")
+	}
+	if _, err := execute(t, srv, "domain", "rm", "api", "api.com"); err == nil || !strings.Contains(err.Error(), "--clear") {
+		t.Errorf("removing the redirect domain should be refused, got %v", err)
+	}
+
+	if _, err := execute(t, srv, "domain", "redirect", "api", "--clear"); err != nil {
+		t.Fatalf("domain redirect --clear: %v", err)
+	}
+	if _, err := execute(t, srv, "domain", "rm", "api", "api.com"); err != nil {
+		t.Fatalf("domain rm: %v", err)
+	}
+	app, _ := srv.App("api")
+	if app.RedirectDomain != "" || len(app.CustomDomain) != 1 || app.CustomDomain[0].PublicDomain != "www.api.com" {
+		t.Errorf("domains not updated: %+v", app)
+	}
+}
+
+func TestPreDeployScript(t *testing.T) {
+	const script = "var preDeployFunction = function (captainAppObj, dockerUpdateObject) {\n  return Promise.resolve(dockerUpdateObject);\n};\n"
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", PreDeployFunction: script}, crapi.AppDefinition{AppName: "web"})
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	ws := filepath.Join(dir, "127-0-0-1")
+	file := filepath.Join(ws, "predeploy", "api.js")
+	if data, err := os.ReadFile(file); err != nil || string(data) != script {
+		t.Fatalf("script not exported: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "predeploy", "web.js")); !os.IsNotExist(err) {
+		t.Error("apps without a script should not get one")
+	}
+
+	config := filepath.Join(ws, "api.yml")
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("exported config should be up to date, got %q, %v", out, err)
+	}
+
+	changed := strings.Replace(script, "Promise.resolve(dockerUpdateObject)", "dockerUpdateObject", 1)
+	if err := os.WriteFile(file, []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, srv, "validate", config, filepath.Join(ws, "web.yml")); err != nil || strings.Count(out, ": ok") != 2 {
+		t.Errorf("validate should pass, got %q, %v", out, err)
+	}
+	if _, err := execute(t, srv, "apply", config); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if app, _ := srv.App("api"); app.PreDeployFunction != changed {
+		t.Errorf("script not uploaded: %q", app.PreDeployFunction)
+	}
+
+	if err := os.WriteFile(file, []byte("var preDeployFunction = function (a, b) {\n  return b;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, srv, "validate", config); err == nil || !strings.Contains(out, "'{' opened at line 1 isn't closed") {
+		t.Errorf("validate should report the unclosed brace, got %q, %v", out, err)
+	}
+	if _, err := execute(t, srv, "apply", config); err == nil {
+		t.Error("apply should refuse an invalid script")
+	}
+	if app, _ := srv.App("api"); app.PreDeployFunction != changed {
+		t.Error("an invalid script must not be uploaded")
+	}
+}
+
+func TestNodeAndNetworks(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "api", InstanceCount: 2, Networks: []string{"captain-overlay-network"}})
+	srv.Nodes = []crapi.Node{
+		{NodeID: "n1", Hostname: "worker-1", State: "ready", Status: crapi.NodeActive},
+		{NodeID: "n2", Hostname: "worker-2", State: "ready", Status: crapi.NodeDrain},
+	}
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	config := filepath.Join(dir, "127-0-0-1", "api.yml")
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("exported config should be up to date, got %q, %v", out, err)
+	}
+
+	if err := workspace.SetValue(config, "worker-1", "Node"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := execute(t, srv, "apply", config)
+	if err != nil || !strings.Contains(out, "Node: none -> n1") {
+		t.Fatalf("apply should pin the node by hostname, got %q, %v", out, err)
+	}
+	app, _ := srv.App("api")
+	if app.NodeID != "n1" || len(app.Networks) != 1 || app.InstanceCount != 2 {
+		t.Errorf("node not applied or settings lost: %+v", app)
+	}
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "up to date") || strings.Contains(out, "Warning") {
+		t.Errorf("a hostname should match the pinned node ID, got %q, %v", out, err)
+	}
+	if got := srv.Requests(crapi.URLSystemNodesPath); got != 2 {
+		t.Errorf("nodes listed %d times for an apply and a plan, want 2", got)
+	}
+
+	if err := workspace.SetValue(config, []string{"captain-overlay-network", "db-net"}, "Networks"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, srv, "plan", config); err == nil || !strings.Contains(err.Error(), "networks can't be changed") {
+		t.Errorf("plan should reject changed networks, got %v", err)
+	}
+	if err := workspace.SetValue(config, []string{"captain-overlay-network"}, "Networks"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := workspace.SetValue(config, workspace.NodeNone, "Node"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, srv, "apply", config); err != nil || !strings.Contains(out, "Node: n1 -> none") {
+		t.Fatalf("apply should unpin the node, got %q, %v", out, err)
+	}
+	if app, _ := srv.App("api"); app.NodeID != "" {
+		t.Errorf("node not unpinned: %+v", app)
+	}
+
+	if err := workspace.SetValue(config, "worker-2", "Node"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "Warning: app 'api': node worker-2 is drained") {
+		t.Errorf("plan should warn about the drained node, got %q, %v", out, err)
+	}
+
+	if err := workspace.SetValue(config, "worker-9", "Node"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, srv, "plan", config); err != nil || !strings.Contains(out, "node worker-9 doesn't exist") {
+		t.Errorf("plan should warn about the missing node, got %q, %v", out, err)
+	}
+	if _, err := execute(t, srv, "apply", config); err == nil || !strings.Contains(err.Error(), "node not found") {
+		t.Errorf("apply should fail for a missing node, got %v", err)
+	}
+}
+
+func TestExportedWorkspaceIsUpToDate(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "bare"},
+		crapi.AppDefinition{
+			AppName:                           "full",
+			Description:                       "The public API",
+			InstanceCount:                     3,
+			CaptainDefinitionRelativeFilePath: "./deploy/captain-definition",
+			NotExposeAsWebApp:                 true,
+			ForceSsl:                          true,
+			WebsocketSupport:                  true,
+			ContainerHTTPPort:                 3000,
+			CustomDomain:                      []crapi.CustomDomain{{PublicDomain: "api.com", HasSsl: true}},
+			RedirectDomain:                    "api.com",
+			Tags:                              []crapi.AppTag{{TagName: "team=web"}},
+			NodeID:                            "n1",
+			Networks:                          []string{"captain-overlay-network", "db-net"},
+			PreDeployFunction:                 "var preDeployFunction = function (app, update) { return update; };\n",
+			CustomNginxConfig:                 "server {}\n",
+			// written by hand, not the way apply writes it
+			ServiceUpdateOverride: "TaskTemplate:\n  Resources:\n    Limits:\n      MemoryBytes: 536870912\n",
+			HTTPAuth:              &crapi.HTTPAuth{User: "admin", PasswordHashed: crapitest.HashPassword("s3cret")},
+		},
+	)
+	srv.Nodes = []crapi.Node{{NodeID: "n1", Hostname: "worker-1", State: "ready", Status: crapi.NodeActive}}
+	exported, _ := srv.App("full")
+	exported.AppPushWebhook.RepoInfo = crapi.AppRepoInfo{Repo: "github.com/acme/api", Branch: "main", User: "deploy", Password: "t0k3n"}
+	exported.ProjectID = srv.AddProject("web", "")
+	srv.AddApp(exported)
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	ws := filepath.Join(dir, "127-0-0-1")
+	config := filepath.Join(ws, "web", "full.yml")
+	files := []string{filepath.Join(ws, "bare.yml"), config}
+
+	out, err := execute(t, srv, append([]string{"plan"}, files...)...)
+	if err != nil || !strings.Contains(out, "0 of 2 app(s) to change") {
+		t.Fatalf("a fresh export should plan no changes, got %q, %v", out, err)
+	}
+
+	// every setting of an update request is exported and planned, unless it
+	// is listed here, so a new one can't be left out of the workspace
+	unmanaged := map[string]bool{
+		"AppName": true, "Volumes": true, "Ports": true, "EnvVars": true, "AppDeployTokenConfig": true, "Raw": true,
+	}
+	// settings that can't be reconciled from the exported file alone
+	planErrors := map[string]string{
+		"Networks": "networks can't be changed",
+		"HTTPAuth": "the app has no HTTP auth yet",
+	}
+	fields := reflect.TypeOf(crapi.UpdateAppRequest{})
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i).Name
+		if unmanaged[field] {
+			continue
+		}
+
+		changed := exported
+		value := reflect.ValueOf(&changed).Elem().FieldByName(field)
+		if !value.IsValid() || value.IsZero() {
+			t.Errorf("%s isn't set in the fixture", field)
+			continue
+		}
+		value.Set(reflect.Zero(value.Type()))
+		srv.AddApp(changed)
+
+		out, err := execute(t, srv, "plan", config)
+		if want, ok := planErrors[field]; ok {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("a change of %s should fail the plan with %q, got %q, %v", field, want, out, err)
+			}
+		} else if err != nil || !strings.Contains(out, "App 'full' will be updated") {
+			t.Errorf("a change of %s should be planned, got %q, %v", field, out, err)
+		}
+	}
+	srv.AddApp(exported)
+
+	for path, value := range map[string]any{
+		"Description":                       "",
+		"NotExposeAsWebApp":                 false,
+		"RedirectDomain":                    "",
+		"CaptainDefinitionRelativeFilePath": "./captain-definition",
+	} {
+		if err := workspace.SetValue(config, value, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err = execute(t, srv, "apply", config)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	for _, want := range []string{
+		"Description: The public API -> none",
+		"NotExposeAsWebApp: true -> false",
+		"RedirectDomain: api.com -> none",
+		"CaptainDefinitionRelativeFilePath: ./deploy/captain-definition -> ./captain-definition",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("apply should report %q:\n%s", want, out)
+		}
+	}
+
+	app, _ := srv.App("full")
+	if app.Description != "" || app.NotExposeAsWebApp || app.RedirectDomain != "" || app.CaptainDefinitionRelativeFilePath != "./captain-definition" {
+		t.Errorf("settings not applied: %+v", app)
+	}
+	if app.InstanceCount != 3 || app.HTTPAuth == nil || app.AppPushWebhook.RepoInfo.Password != "t0k3n" || app.PreDeployFunction == "" {
+		t.Errorf("other settings were changed: %+v", app)
+	}
+}
+
+func TestProjects(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "www"})
+	shop := srv.AddProject("shop", "")
+	payments := srv.AddProject("payments", shop)
+	srv.AddApp(crapi.AppDefinition{AppName: "cart", ProjectID: shop})
+	srv.AddApp(crapi.AppDefinition{AppName: "billing", ProjectID: payments, InstanceCount: 2})
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	ws := filepath.Join(dir, "127-0-0-1")
+	for _, file := range []string{"www.yml", "shop/cart.yml", "shop/payments/billing.yml"} {
+		if _, err := os.Stat(filepath.Join(ws, file)); err != nil {
+			t.Errorf("%s not exported: %v", file, err)
+		}
+	}
+	billing := filepath.Join(ws, "shop", "payments", "billing.yml")
+	if data, _ := os.ReadFile(billing); !strings.Contains(string(data), "Project: shop/payments") {
+		t.Errorf("the project should be exported:\n%s", data)
+	}
+	if out, err := execute(t, srv, "plan", billing); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("exported config should be up to date, got %q, %v", out, err)
+	}
+
+	out, err := execute(t, srv, "ls", "--project", "shop")
+	if err != nil || !strings.Contains(out, "- cart") || !strings.Contains(out, "- billing") || strings.Contains(out, "- www") {
+		t.Errorf("ls --project shop should list cart and billing, got %q, %v", out, err)
+	}
+	if out, err := execute(t, srv, "ls", "--project", "shop/payments"); err != nil || strings.Contains(out, "- cart") || !strings.Contains(out, "- billing") {
+		t.Errorf("ls --project shop/payments should list billing only, got %q, %v", out, err)
+	}
+	if _, err := execute(t, srv, "ls", "--project", "nope"); err == nil {
+		t.Error("expected an error for an unknown project")
+	}
+
+	if _, err := execute(t, srv, "project", "create", "shop/search", "--description", "Search"); err != nil {
+		t.Fatalf("project create: %v", err)
+	}
+	if err := workspace.SetValue(billing, "shop/search", "Project"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, srv, "apply", billing); err != nil || !strings.Contains(out, "Project: shop/payments -> shop/search") {
+		t.Fatalf("apply should move the app, got %q, %v", out, err)
+	}
+	if app, _ := srv.App("billing"); crapi.ProjectPath(srv.Projects(), app.ProjectID) != "shop/search" || app.InstanceCount != 2 {
+		t.Errorf("app not moved or settings lost: %+v", app)
+	}
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if _, err := os.Stat(billing); !os.IsNotExist(err) {
+		t.Errorf("the config in the previous project should be removed, got %v", err)
+	}
+	moved := filepath.Join(ws, "shop", "search", "billing.yml")
+	if file, ok := findAppConfig(ws, "billing"); !ok || file != moved {
+		t.Errorf("found billing at %q, want %q", file, moved)
+	}
+	billing = moved
+
+	out, err = execute(t, srv, "project", "ls")
+	if err != nil || !strings.Contains(out, "shop/payments  0     -") || !strings.Contains(out, "shop/search    1     Search") {
+		t.Errorf("project ls should count apps, got %q, %v", out, err)
+	}
+
+	if _, err := execute(t, srv, "project", "rm", "shop"); err == nil {
+		t.Error("a project with apps should not be deleted")
+	}
+	if _, err := execute(t, srv, "project", "rm", "shop/payments"); err != nil {
+		t.Fatalf("project rm: %v", err)
+	}
+	if len(srv.Projects()) != 2 {
+		t.Errorf("got projects %+v, want shop and shop/search", srv.Projects())
+	}
+
+	if err := workspace.SetValue(billing, "shop/gone", "Project"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, srv, "apply", billing); err == nil || !strings.Contains(err.Error(), "project shop/gone not found") {
+		t.Errorf("apply should fail for an unknown project, got %v", err)
+	}
+}
+
+func TestInitWarnings(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "shared", Tags: []crapi.AppTag{{TagName: "team=a"}, {TagName: "team=b"}},
+			ServiceUpdateOverride: "TaskTemplate: {}\n"},
+		crapi.AppDefinition{AppName: "broken", ServiceUpdateOverride: "TaskTemplate: [oops\n"},
+	)
+	chdir(t)
+
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	// the raw override only helps with override errors
+	if got := logs.String(); !strings.Contains(got, "labels of app 'shared' not exported") ||
+		strings.Count(got, "Raw ServiceUpdateOverride") != 1 || !strings.Contains(got, "Raw ServiceUpdateOverride: TaskTemplate: [oops") {
+		t.Errorf("unexpected warnings:\n%s", got)
+	}
+}
+
+func TestInitRejectsUnsafeProjectNames(t *testing.T) {
+	srv := newTestServer(t, crapi.AppDefinition{AppName: "www"})
+	srv.AddApp(crapi.AppDefinition{AppName: "up", ProjectID: srv.AddProject("..", "")})
+	srv.AddApp(crapi.AppDefinition{AppName: "slash", ProjectID: srv.AddProject("a/b", "")})
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	for _, file := range []string{"up.yml", "127-0-0-1/up.yml", "127-0-0-1/a/b/slash.yml"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be written, got %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "127-0-0-1", "www.yml")); err != nil {
+		t.Errorf("the other apps should be exported: %v", err)
+	}
+}
+
+func TestLabelsAndSelectors(t *testing.T) {
+	srv := newTestServer(t,
+		crapi.AppDefinition{AppName: "billing", Tags: []crapi.AppTag{{TagName: "team=payments"}, {TagName: "tier=1"}}},
+		crapi.AppDefinition{AppName: "invoices", Tags: []crapi.AppTag{{TagName: "team=payments"}}},
+		crapi.AppDefinition{AppName: "www", Tags: []crapi.AppTag{{TagName: "team=web"}}},
+		crapi.AppDefinition{AppName: "shared", Tags: []crapi.AppTag{{TagName: "team=a"}, {TagName: "team=b"}}},
+	)
+	dir := chdir(t)
+
+	if _, err := execute(t, srv, "init"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	ws := filepath.Join(dir, "127-0-0-1")
+	billing := filepath.Join(ws, "billing.yml")
+	if data, _ := os.ReadFile(billing); !strings.Contains(string(data), "team: payments") {
+		t.Errorf("the labels should be exported:\n%s", data)
+	}
+	if out, err := execute(t, srv, "plan", billing); err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("exported config should be up to date, got %q, %v", out, err)
+	}
+
+	// a key set twice has no single value, applying must not drop a tag
+	shared := filepath.Join(ws, "shared.yml")
+	if data, _ := os.ReadFile(shared); strings.Contains(string(data), "Labels") {
+		t.Errorf("labels with a key set twice should not be exported:\n%s", data)
+	}
+	if _, err := execute(t, srv, "apply", shared); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if app, _ := srv.App("shared"); len(app.Tags) != 2 {
+		t.Errorf("got tags %+v, want both kept", app.Tags)
+	}
+
+	out, err := execute(t, srv, "ls", "-l", "team=payments,tier!=1")
+	if err != nil || strings.Contains(out, "- billing") || !strings.Contains(out, "- invoices") || strings.Contains(out, "- www") {
+		t.Errorf("ls -l should list invoices only, got %q, %v", out, err)
+	}
+
+	out, err = execute(t, srv, "restart", "--selector", "team!=payments")
+	if err != nil || !strings.Contains(out, "App 'www' restarted") || strings.Contains(out, "billing") {
+		t.Errorf("restart -l should restart www only, got %q, %v", out, err)
+	}
+
+	if _, err := execute(t, srv, "scale", "-l", "team=payments", "2"); err != nil {
+		t.Fatalf("scale -l: %v", err)
+	}
+	for name, want := range map[string]int{"billing": 2, "invoices": 2, "www": 0} {
+		if app, _ := srv.App(name); app.InstanceCount != want {
+			t.Errorf("app %s has %d instance(s), want %d", name, app.InstanceCount, want)
+		}
+	}
+	if data, _ := os.ReadFile(billing); !strings.Contains(string(data), "Instances: 2") {
+		t.Errorf("scale should update the workspace:\n%s", data)
+	}
+
+	// a failing app doesn't stop the others
+	srv.Inject(crapitest.Fault{Path: crapi.URLUpdateAppPath, Times: 1, HTTPStatus: http.StatusInternalServerError})
+	if _, err := execute(t, srv, "scale", "-l", "team=payments", "3"); err == nil || !strings.Contains(err.Error(), "error scaling app") {
+		t.Errorf("scale -l should report the failed app, got %v", err)
+	}
+	scaled := 0
+	for _, name := range []string{"billing", "invoices"} {
+		if app, _ := srv.App(name); app.InstanceCount == 3 {
+			scaled++
+		}
+	}
+	if scaled != 1 {
+		t.Errorf("%d app(s) scaled, want the one that didn't fail", scaled)
+	}
+
+	if err := workspace.SetValue(filepath.Join(ws, "www.yml"), "frontend", "Labels", "team"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = execute(t, srv, "apply", "-l", "team=frontend")
+	if err != nil || !strings.Contains(out, "Labels: team=web -> team=frontend") || strings.Contains(out, "billing") {
+		t.Fatalf("apply -l should update www only, got %q, %v", out, err)
+	}
+	if app, _ := srv.App("www"); len(app.Tags) != 1 || app.Tags[0].TagName != "team=frontend" {
+		t.Errorf("got tags %+v, want team=frontend", app.Tags)
+	}
+
+	if _, err := execute(t, srv, "restart", "www", "-l", "team=frontend"); err == nil {
+		t.Error("expected an error for both app names and a selector")
+	}
+	if _, err := execute(t, srv, "restart", "-l", "team=nobody"); err == nil {
+		t.Error("expected an error when no app matches")
+	}
+	if _, err := execute(t, srv, "ls", "-l", "=x"); err == nil {
+		t.Error("expected an error for an invalid selector")
+	}
+}
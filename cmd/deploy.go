@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+// defaultCaptainDefinitionPath is used for apps without a custom
+// captain-definition location.
+const defaultCaptainDefinitionPath = "./captain-definition"
+
+// buildPollInterval is how often build status is polled while waiting.
+var buildPollInterval = 2 * time.Second
+
+var (
+	deployTimeout  time.Duration
+	deployImage    string
+	deployAppToken string
+)
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy <app> [dir]",
+	Short: "Deploy an app from a local source directory or a prebuilt image",
+	Long: `Deploy an app from a local source directory, the current directory by default.
+
+The directory is uploaded as a tarball, leaving out .git and the files matched by its
+.gitignore and .dockerignore. It must contain the app's captain-definition file. The
+command waits for the build to finish.
+
+With --image, CapRover pulls and runs a prebuilt Docker image instead. The command
+waits until the new version is the deployed one.
+
+With --app-token, the command authenticates with the deploy token of the app
+instead of the password, see the deploy-token command. Such a token can't read the
+app settings, so the captain-definition file must be at the root of the directory.`,
+	Example: "letgofur deploy my-app\nletgofur deploy my-app ./services/my-app\nletgofur deploy my-app --image registry.example.com/my-app:1.4.2\nletgofur deploy my-app --host captain.your.domain --app-token $APP_TOKEN",
+	Args:    cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		if deployImage != "" {
+			if len(args) > 1 {
+				return fmt.Errorf("a source directory can't be used together with --image")
+			}
+			if deployAppToken != "" {
+				return deployImageWithToken(client, appName, deployImage)
+			}
+			return deployImageTo(client, appName, deployImage)
+		}
+
+		dir := "."
+		if len(args) == 2 {
+			dir = args[1]
+		}
+
+		definitionPath := defaultCaptainDefinitionPath
+		if deployAppToken == "" {
+			app, err := client.GetAppDetailFor(appName)
+			if err != nil {
+				return fmt.Errorf("error getting app details: %w", err)
+			}
+			if app.CaptainDefinitionRelativeFilePath != "" {
+				definitionPath = app.CaptainDefinitionRelativeFilePath
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(definitionPath))); err != nil {
+			return fmt.Errorf("captain-definition not found at %s in %s: %w", definitionPath, dir, err)
+		}
+
+		archive, err := createSourceArchive(dir, definitionPath)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		// lines of earlier builds must not be shown as part of this one
+		before, err := client.GetBuildStatus(appName)
+		if err != nil {
+			return fmt.Errorf("error getting build status: %w", err)
+		}
+
+		fmt.Printf("Uploading %d files (%s) to app '%s'...\n", archive.Files, formatBytes(archive.Size), appName)
+
+		err = client.DeploySourceArchive(appName, archive, archive.Size, printUploadProgress)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("error uploading source: %w", err)
+		}
+
+		return waitForDeployBuild(client, appName, before)
+	},
+}
+
+// waitForDeployBuild follows the build started by a deploy. before is the
+// build status from before the deploy, its lines aren't printed again.
+func waitForDeployBuild(client Client, appName string, before crapi.AppBuildLogData) error {
+	fmt.Printf("Waiting for the build of app '%s' to finish...\n", appName)
+	_, err := client.WatchBuild(appName, crapi.BuildWatchOptions{
+		Interval: buildPollInterval,
+		Timeout:  deployTimeout,
+		Seen:     before.Logs.Lines,
+		OnLine:   printBuildLogLine,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("App '%s' deployed successfully!\n", appName)
+	return nil
+}
+
+// deployImageWithToken deploys a prebuilt image with a deploy token. The
+// token can't list apps, so the build is followed instead of the versions.
+func deployImageWithToken(client Client, appName string, image string) error {
+	before, err := client.GetBuildStatus(appName)
+	if err != nil {
+		return fmt.Errorf("error getting build status: %w", err)
+	}
+
+	fmt.Printf("Deploying image '%s' to app '%s'...\n", image, appName)
+	if err := client.DeployImage(appName, image); err != nil {
+		return fmt.Errorf("error deploying image: %w", err)
+	}
+
+	return waitForDeployBuild(client, appName, before)
+}
+
+// deployImageTo deploys a prebuilt image and waits for it to become the
+// deployed version.
+func deployImageTo(client Client, appName string, image string) error {
+	app, err := client.GetAppDetailFor(appName)
+	if err != nil {
+		return fmt.Errorf("error getting app details: %w", err)
+	}
+
+	previous := -1
+	if latest, ok := crapi.LatestVersion(app); ok {
+		previous = latest.Version
+	}
+
+	fmt.Printf("Deploying image '%s' to app '%s'...\n", image, appName)
+	if err := client.DeployImage(appName, image); err != nil {
+		return fmt.Errorf("error deploying image: %w", err)
+	}
+
+	fmt.Printf("Waiting for the new version of app '%s' to become active...\n", appName)
+	app, err = client.WaitForVersion(appName, previous, buildPollInterval, deployTimeout)
+	if err != nil {
+		printBuildFailure(err)
+		return err
+	}
+
+	printDeployedVersion(app)
+	return nil
+}
+
+// printDeployedVersion reports the version an app is running.
+func printDeployedVersion(app crapi.AppDefinition) {
+	for _, v := range app.Versions {
+		if v.Version != app.DeployedVersion {
+			continue
+		}
+
+		fmt.Printf("App '%s' is running version %d (image %s) with %d instance(s).\n",
+			app.AppName, v.Version, v.DeployedImageName, app.InstanceCount)
+		return
+	}
+
+	fmt.Printf("App '%s' is running version %d with %d instance(s).\n", app.AppName, app.DeployedVersion, app.InstanceCount)
+}
+
+func printUploadProgress(sent int64, total int64) {
+	percent := 100
+	if total > 0 {
+		percent = int(sent * 100 / total)
+	}
+	fmt.Fprintf(os.Stderr, "\rUploading... %3d%% (%s / %s)", percent, formatBytes(sent), formatBytes(total))
+}
+
+// formatBytes formats a size in bytes for humans.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	deployCmd.Flags().DurationVar(&deployTimeout, "timeout", 15*time.Minute, "How long to wait for the build to finish")
+	deployCmd.Flags().StringVar(&deployAppToken, "app-token", "", "Authenticate with the deploy token of the app instead of --passwd")
+	deployCmd.Flags().StringVar(&deployImage, "image", "", "Deploy this prebuilt Docker image, e.g. repo/name:tag, instead of a source directory")
+
+	rootCmd.AddCommand(deployCmd)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var deployTokenCmd = &cobra.Command{
+	Use:   "deploy-token",
+	Short: "Manage the deploy token of an app",
+	Long: `Manage the deploy token of an app.
+
+A deploy token only allows deploying its app, so CI pipelines can use it with
+'letgofur deploy --app-token' instead of the admin password.`,
+}
+
+var deployTokenEnableCmd = &cobra.Command{
+	Use:     "enable <app>",
+	Short:   "Enable the deploy token of an app and print it",
+	Example: "letgofur deploy-token enable my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setDeployToken(clientFrom(cmd), args[0], crapi.AppDeployTokenConfig{Enabled: true}, false)
+	},
+}
+
+var deployTokenDisableCmd = &cobra.Command{
+	Use:     "disable <app>",
+	Short:   "Disable the deploy token of an app",
+	Example: "letgofur deploy-token disable my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setDeployToken(clientFrom(cmd), args[0], crapi.AppDeployTokenConfig{Enabled: false}, false)
+	},
+}
+
+var deployTokenRotateCmd = &cobra.Command{
+	Use:     "rotate <app>",
+	Short:   "Replace the deploy token of an app with a new one and print it",
+	Example: "letgofur deploy-token rotate my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setDeployToken(clientFrom(cmd), args[0], crapi.AppDeployTokenConfig{Enabled: true}, true)
+	},
+}
+
+var deployTokenShowCmd = &cobra.Command{
+	Use:     "show <app>",
+	Short:   "Print the deploy token of an app",
+	Example: "letgofur deploy-token show my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := clientFrom(cmd).GetAppDetailFor(args[0])
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+
+		printDeployToken(app)
+		return nil
+	},
+}
+
+// setDeployToken updates the deploy token settings of an app. An enabled
+// config without a token makes CapRover generate one: that's how tokens are
+// rotated.
+func setDeployToken(client Client, appName string, config crapi.AppDeployTokenConfig, rotate bool) error {
+	app, err := client.GetAppDetailFor(appName)
+	if err != nil {
+		return fmt.Errorf("error getting app details: %w", err)
+	}
+
+	// enabling an enabled token must keep it
+	if rotate || app.AppDeployTokenConfig.Enabled != config.Enabled {
+		err := client.PatchApp(appName, func(req *crapi.UpdateAppRequest) error {
+			req.AppDeployTokenConfig = config
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error updating deploy token: %w", err)
+		}
+
+		if app, err = client.GetAppDetailFor(appName); err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+	}
+
+	printDeployToken(app)
+	return nil
+}
+
+func printDeployToken(app crapi.AppDefinition) {
+	config := app.AppDeployTokenConfig
+	if !config.Enabled {
+		fmt.Printf("The deploy token of app '%s' is disabled.\n", app.AppName)
+		return
+	}
+
+	fmt.Println(config.AppDeployToken)
+}
+
+func init() {
+	deployTokenCmd.AddCommand(deployTokenEnableCmd, deployTokenDisableCmd, deployTokenRotateCmd, deployTokenShowCmd)
+
+	rootCmd.AddCommand(deployTokenCmd)
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// diffOp is a line of a diff: ' ' unchanged, '-' removed or '+' added.
+type diffOp struct {
+	kind byte
+	text string
+	// line numbers in the old and new text, starting at 1
+	oldLine, newLine int
+}
+
+// unifiedDiff returns the unified diff between two texts, or an empty string
+// when they are the same.
+func unifiedDiff(oldName string, newName string, oldText string, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// extend the hunk while changes are close enough to share context
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i
+			} else if i-end > 2*diffContext {
+				break
+			}
+		}
+
+		from := max(start-diffContext, 0)
+		to := min(end+diffContext+1, len(ops))
+		writeHunk(&b, ops[from:to])
+
+		start = to
+	}
+
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, ops []diffOp) {
+	oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			if oldCount == 0 {
+				oldStart = op.oldLine
+			}
+			oldCount++
+		}
+		if op.kind != '-' {
+			if newCount == 0 {
+				newStart = op.newLine
+			}
+			newCount++
+		}
+	}
+	// an empty side starts at the line before the hunk, as in diff -u
+	if oldCount == 0 {
+		oldStart = ops[0].oldLine - 1
+	}
+	if newCount == 0 {
+		newStart = ops[0].newLine - 1
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, op := range ops {
+		fmt.Fprintf(b, "%c%s\n", op.kind, op.text)
+	}
+}
+
+// maxDiffCells caps the size of the table diffLines fills to find the longest
+// common subsequence, len(a)*len(b) once the common prefix and suffix are
+// left out. Larger changes are shown as a whole replacement.
+const maxDiffCells = 1 << 20
+
+// diffLines computes the line operations turning a into b from their longest
+// common subsequence.
+func diffLines(a []string, b []string) []diffOp {
+	// the common prefix and suffix are unchanged, only the middle is diffed
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{' ', a[i], i + 1, i + 1})
+	}
+
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(middleA)*len(middleB) > maxDiffCells {
+		ops = append(ops, replaceLines(middleA, middleB, prefix)...)
+	} else {
+		ops = append(ops, lcsDiff(middleA, middleB, prefix)...)
+	}
+
+	for k := suffix; k > 0; k-- {
+		i, j := len(a)-k, len(b)-k
+		ops = append(ops, diffOp{' ', a[i], i + 1, j + 1})
+	}
+
+	return ops
+}
+
+// lcsDiff diffs a and b from their longest common subsequence. offset is the
+// number of lines before them.
+func lcsDiff(a []string, b []string, offset int) []diffOp {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], offset + i + 1, offset + j + 1})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], offset + i + 1, offset + j + 1})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], offset + i + 1, offset + j + 1})
+			j++
+		}
+	}
+
+	return ops
+}
+
+// replaceLines removes all of a and adds all of b. offset is the number of
+// lines before them.
+func replaceLines(a []string, b []string, offset int) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i, line := range a {
+		ops = append(ops, diffOp{'-', line, offset + i + 1, offset + 1})
+	}
+	for j, line := range b {
+		ops = append(ops, diffOp{'+', line, offset + len(a) + 1, offset + j + 1})
+	}
+
+	return ops
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+
+	want := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	if got := unifiedDiff("old", "new", old, new); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := unifiedDiff("old", "new", old, old); got != "" {
+		t.Errorf("identical texts should have no diff, got %q", got)
+	}
+
+	if got := unifiedDiff("old", "new", "", "x\n"); got != "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+x\n" {
+		t.Errorf("unexpected diff from empty text: %q", got)
+	}
+}
+
+func TestUnifiedDiffOfLargeTexts(t *testing.T) {
+	var old, new strings.Builder
+	old.WriteString("head\n")
+	new.WriteString("head\n")
+	for i := range 1100 {
+		fmt.Fprintf(&old, "old %d\n", i)
+		fmt.Fprintf(&new, "new %d\n", i)
+	}
+	old.WriteString("tail\n")
+	new.WriteString("tail\n")
+
+	// too large to diff line by line, the middle is replaced as a whole
+	got := unifiedDiff("old", "new", old.String(), new.String())
+	if !strings.HasPrefix(got, "--- old\n+++ new\n@@ -1,1102 +1,1102 @@\n head\n-old 0\n") {
+		t.Errorf("unexpected hunk start:\n%.200s", got)
+	}
+	if i := strings.Index(got, "-old 1099\n+new 0\n"); i < 0 || !strings.HasSuffix(got, "+new 1099\n tail\n") {
+		t.Errorf("the old lines should all be removed before the new ones are added:\n%s", got[len(got)-200:])
+	}
+
+	// a change in a large text still gets a small hunk
+	changed := strings.Replace(old.String(), "old 500\n", "changed\n", 1)
+	want := "--- old\n+++ new\n@@ -499,7 +499,7 @@\n old 497\n old 498\n old 499\n-old 500\n+changed\n old 501\n old 502\n old 503\n"
+	if got := unifiedDiff("old", "new", old.String(), changed); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
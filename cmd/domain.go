@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	domainSSL           bool
+	domainRedirectClear bool
+)
+
+var domainCmd = &cobra.Command{
+	Use:   "domain",
+	Short: "Manage the domains of an app",
+	Long: `Manage the domains of an app.
+
+Every subcommand prints the domains of the app with their SSL status.`,
+}
+
+var domainLsCmd = &cobra.Command{
+	Use:     "ls <app>",
+	Short:   "List the domains of an app",
+	Example: "letgofur domain ls my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printDomains(clientFrom(cmd), args[0])
+	},
+}
+
+var domainAddCmd = &cobra.Command{
+	Use:   "add <app> <domain...>",
+	Short: "Add custom domains to an app",
+	Long: `Add custom domains to an app.
+
+The domains must already point to the CapRover instance. With --ssl, a Let's
+Encrypt certificate is requested for each of them.`,
+	Example: "letgofur domain add my-app example.com www.example.com --ssl",
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		var errs []error
+		for _, domain := range args[1:] {
+			if err := client.AddCustomDomain(appName, domain); err != nil {
+				errs = append(errs, fmt.Errorf("error adding domain %s to app '%s': %w", domain, appName, err))
+				continue
+			}
+			if domainSSL {
+				if err := client.EnableCustomDomainSSL(appName, domain); err != nil {
+					errs = append(errs, fmt.Errorf("error enabling SSL on domain %s: %w", domain, err))
+				}
+			}
+		}
+
+		return errors.Join(append(errs, printDomains(client, appName))...)
+	},
+}
+
+var domainRmCmd = &cobra.Command{
+	Use:     "rm <app> <domain...>",
+	Short:   "Remove custom domains from an app",
+	Example: "letgofur domain rm my-app www.example.com",
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		app, err := client.GetAppDetailFor(appName)
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+
+		var errs []error
+		for _, domain := range args[1:] {
+			if domain == app.RedirectDomain {
+				errs = append(errs, fmt.Errorf("domain %s is the redirect domain of app '%s', run 'letgofur domain redirect %s --clear' first", domain, appName, appName))
+				continue
+			}
+			if err := client.RemoveCustomDomain(appName, domain); err != nil {
+				errs = append(errs, fmt.Errorf("error removing domain %s from app '%s': %w", domain, appName, err))
+			}
+		}
+
+		return errors.Join(append(errs, printDomains(client, appName))...)
+	},
+}
+
+var domainSSLCmd = &cobra.Command{
+	Use:   "ssl <app> [domain...]",
+	Short: "Enable SSL on the domains of an app",
+	Long: `Enable SSL on the domains of an app.
+
+Without domains, SSL is enabled on the base domain of the app, which must be
+done before enabling it on custom domains.`,
+	Example: "letgofur domain ssl my-app\nletgofur domain ssl my-app example.com",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		var errs []error
+		if len(args) == 1 {
+			if err := client.EnableBaseDomainSSL(appName); err != nil {
+				errs = append(errs, fmt.Errorf("error enabling SSL on the base domain of app '%s': %w", appName, err))
+			}
+		}
+		for _, domain := range args[1:] {
+			if err := client.EnableCustomDomainSSL(appName, domain); err != nil {
+				errs = append(errs, fmt.Errorf("error enabling SSL on domain %s: %w", domain, err))
+			}
+		}
+
+		return errors.Join(append(errs, printDomains(client, appName))...)
+	},
+}
+
+var domainRedirectCmd = &cobra.Command{
+	Use:   "redirect <app> [domain]",
+	Short: "Redirect every domain of an app to one of them",
+	Long: `Redirect every domain of an app to one of them, e.g. www.example.com and
+the base domain to example.com. Use --clear to stop redirecting.`,
+	Example: "letgofur domain redirect my-app example.com\nletgofur domain redirect my-app --clear",
+	Args:    cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		domain := ""
+		switch {
+		case domainRedirectClear && len(args) == 2:
+			return fmt.Errorf("--clear doesn't take a domain")
+		case !domainRedirectClear && len(args) == 1:
+			return fmt.Errorf("a domain or --clear is required")
+		case len(args) == 2:
+			domain = args[1]
+		}
+
+		if err := client.SetRedirectDomain(appName, domain); err != nil {
+			return fmt.Errorf("error setting the redirect domain of app '%s': %w", appName, err)
+		}
+
+		return printDomains(client, appName)
+	},
+}
+
+// printDomains prints the base and custom domains of an app with their SSL
+// status as a table.
+func printDomains(client Client, appName string) error {
+	details, err := client.GetAppDetails()
+	if err != nil {
+		return fmt.Errorf("error getting app details: %w", err)
+	}
+
+	var app crapi.AppDefinition
+	found := false
+	for _, def := range details.Data.AppDefinitions {
+		if def.AppName == appName {
+			app, found = def, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("app %s not found", appName)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOMAIN\tSSL\tNOTES")
+	if root := details.Data.RootDomain; root != "" {
+		base := app.AppName + "." + root
+		fmt.Fprintf(w, "%s\t%s\t%s\n", base, yesNo(app.HasDefaultSubDomainSsl), domainNotes(app, base, "base domain"))
+	}
+	for _, d := range app.CustomDomain {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.PublicDomain, yesNo(d.HasSsl), domainNotes(app, d.PublicDomain, ""))
+	}
+	return w.Flush()
+}
+
+func domainNotes(app crapi.AppDefinition, domain string, note string) string {
+	if domain == app.RedirectDomain {
+		if note != "" {
+			return note + ", redirect target"
+		}
+		return "redirect target"
+	}
+	return orDash(note)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func init() {
+	domainAddCmd.Flags().BoolVar(&domainSSL, "ssl", false, "Also enable SSL on the added domains")
+	domainRedirectCmd.Flags().BoolVar(&domainRedirectClear, "clear", false, "Stop redirecting to a single domain")
+
+	domainCmd.AddCommand(domainLsCmd, domainAddCmd, domainRmCmd, domainSSLCmd, domainRedirectCmd)
+	rootCmd.AddCommand(domainCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var gitInfoCmd = &cobra.Command{
+	Use:   "git-info <app>",
+	Short: "Show the git repository and push webhook of an app",
+	Long: `Show the git repository an app is built from and the URL of its push webhook.
+
+Add the webhook URL to the repository in GitHub, GitLab or Bitbucket to build the
+app on every push to the branch.`,
+	Example: "letgofur git-info my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+
+		app, err := client.GetAppDetailFor(args[0])
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+
+		repo := app.AppPushWebhook.RepoInfo
+		if repo.Repo == "" {
+			return fmt.Errorf("app '%s' has no git repository, add a Git section to its configuration and apply it", app.AppName)
+		}
+
+		auth := "none"
+		switch {
+		case repo.SSHKey != "":
+			auth = "SSH key"
+		case repo.User != "" || repo.Password != "":
+			auth = fmt.Sprintf("user %s with password", repo.User)
+		}
+
+		fmt.Printf("Repository: %s\n", repo.Repo)
+		fmt.Printf("Branch:     %s\n", repo.Branch)
+		fmt.Printf("Auth:       %s\n", auth)
+
+		token := app.AppPushWebhook.PushWebhookToken
+		if token == "" {
+			fmt.Println("Webhook:    not available yet, CapRover creates it with the repository settings")
+			return nil
+		}
+
+		fmt.Printf("Webhook:    %s\n", client.PushWebhookURL(token))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gitInfoCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <app>",
+	Short: "Show the deployed versions of an app",
+	Long: `Show the deployed versions of an app, newest first.
+
+The version the app is running is marked with an asterisk.`,
+	Example: "letgofur history my-app",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := clientFrom(cmd).GetAppDetailFor(args[0])
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+
+		if len(app.Versions) == 0 {
+			fmt.Printf("App '%s' has not been deployed yet.\n", app.AppName)
+			return nil
+		}
+
+		printHistory(app)
+		return nil
+	},
+}
+
+// printHistory prints the versions of an app as a table, newest first.
+func printHistory(app crapi.AppDefinition) {
+	versions := append([]crapi.AppVersion(nil), app.Versions...)
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tVERSION\tDEPLOYED AT\tIMAGE\tGIT HASH")
+	for _, v := range versions {
+		mark := ""
+		if v.Version == app.DeployedVersion {
+			mark = "*"
+		}
+
+		deployedAt := "-"
+		if !v.TimeStamp.IsZero() {
+			deployedAt = v.TimeStamp.Local().Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", mark, v.Version, deployedAt, orDash(v.DeployedImageName), orDash(shortHash(v.GitHash)))
+	}
+	w.Flush()
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
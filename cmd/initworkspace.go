@@ -1,44 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
+	"github.com/pararang/letgofur/workspace"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
-// AppConfig represents the configuration for an app
-type AppConfig struct {
-	AppName   string    `yaml:"AppName"`
-	Instances int       `yaml:"Instances"`
-	Resources Resources `yaml:"Resources"`
-}
-
-type Resources struct {
-	Limits       Resource `yaml:"Limits"`
-	Reservations Resource `yaml:"Reservations"`
-}
-
-type Resource struct {
-	MemoryBytes *int64 `yaml:"MemoryBytes"`
-	NanoCPUs    *int64 `yaml:"NanoCPUs"`
-}
-
-type TaskTemplate struct {
-	Resources Resources `yaml:"Resources"`
-}
-
-// ServiceUpdateOverride represents the structure of the ServiceUpdateOverride field
-type ServiceUpdateOverride struct {
-	TaskTemplate TaskTemplate `yaml:"TaskTemplate"`
-}
-
 var initGit bool
 
 var initWorkspace = &cobra.Command{
@@ -48,13 +21,8 @@ var initWorkspace = &cobra.Command{
 	Example: "letgofur init --host=<host> --passwd=<password> [--git]",
 	Aliases: []string{"initialize", "setup"},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		parsedURL, err := url.Parse(host)
-		if err != nil {
-			log.Fatalf("Error parsing host URL: %v", err)
-		}
-
-		hostname := parsedURL.Hostname()
-		dirName := strings.ReplaceAll(hostname, ".", "-")
+		captain := clientFrom(cmd)
+		dirName := workspaceDirName(captain)
 
 		currentDir, err := os.Getwd()
 		if err != nil {
@@ -72,6 +40,15 @@ var initWorkspace = &cobra.Command{
 			log.Fatalf("Error getting app details: %v", err)
 		}
 
+		// older CapRover versions have no projects, their apps stay at the top
+		projects, err := captain.GetProjects()
+		if err != nil {
+			log.Printf("Warning: projects not exported: %v", err)
+		}
+
+		// the config file written for each app, to clean up older copies
+		written := make(map[string]string, len(appDetails.Data.AppDefinitions))
+
 		// Process apps in batches to avoid excessive memory usage
 		const batchSize = 10
 		for i := 0; i < len(appDetails.Data.AppDefinitions); i += batchSize {
@@ -81,48 +58,55 @@ var initWorkspace = &cobra.Command{
 			}
 
 			for _, app := range appDetails.Data.AppDefinitions[i:end] {
-				config := AppConfig{
-					AppName:   app.AppName,
-					Instances: app.InstanceCount,
-				}
-
-				// Extract resource limits if available
-				if app.ServiceUpdateOverride != "" {
-					// The ServiceUpdateOverride is a YAML string
-					var suo ServiceUpdateOverride
-
-					err := yaml.Unmarshal([]byte(app.ServiceUpdateOverride), &suo)
-					if err != nil {
-						log.Printf("Error parsing ServiceUpdateOverride for app '%s': %v", app.AppName, err)
+				config, files, err := workspace.ExportFiles(app, appDetails.Data.DefaultNginxConfig)
+				if err != nil {
+					log.Printf("%v", err)
+					if errors.Is(err, workspace.ErrServiceUpdateOverride) {
 						log.Printf("Raw ServiceUpdateOverride: %s", app.ServiceUpdateOverride)
-						config.Resources = Resources{}
-					} else {
-						config.Resources = suo.TaskTemplate.Resources
 					}
-				} else {
-					config.Resources = Resources{}
 				}
 
-				// Convert config to YAML
-				yamlData, err := yaml.Marshal(config)
+				// Write YAML to file, in the directory of its project
+				configFile, project, err := appConfigFile(workspaceDir, projects, app)
 				if err != nil {
-					log.Printf("Error marshaling config for app '%s': %v", app.AppName, err)
+					log.Printf("Error exporting app '%s': %v", app.AppName, err)
 					continue
 				}
-
-				// Write YAML to file
-				configFile := filepath.Join(workspaceDir, fmt.Sprintf("%s.yml", app.AppName))
-				if err := os.WriteFile(configFile, yamlData, 0644); err != nil {
-					log.Printf("Error writing config for app '%s': %v", app.AppName, err)
+				if project != "" {
+					config.Project = &project
+					if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+						log.Printf("Error creating project directory: %v", err)
+						continue
+					}
+				}
+				if err := workspace.Save(configFile, config); err != nil {
+					log.Printf("%v", err)
 					continue
 				}
+				written[app.AppName] = configFile
+				if err := workspace.SaveFiles(configFile, files); err != nil {
+					log.Printf("%v", err)
+				}
 
 				fmt.Printf("Generated config for app '%s' at '%s'\n", app.AppName, configFile)
 			}
 		}
 
+		// an app moved to another project since the last init leaves its
+		// previous config behind, which would be found instead of the new one
+		walkAppConfigs(workspaceDir, func(path string, config workspace.AppConfig) bool {
+			if file, ok := written[config.AppName]; ok && file != path {
+				if err := os.Remove(path); err != nil {
+					log.Printf("Warning: stale config for app '%s' not removed: %v", config.AppName, err)
+				} else {
+					fmt.Printf("Removed stale config for app '%s' at '%s'\n", config.AppName, path)
+				}
+			}
+			return true
+		})
+
 		fmt.Printf("\nConfiguration folder structure created at '%s'\n", workspaceDir)
-		fmt.Printf("This folder contains configuration files for all apps in the CapRover instance at %s\n", host)
+		fmt.Printf("This folder contains configuration files for all apps in the CapRover instance at %s\n", captain.BaseURL())
 		
 		// Initialize git repository if the flag is provided
 		if initGit {
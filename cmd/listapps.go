@@ -2,23 +2,68 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/workspace"
 	"github.com/spf13/cobra"
 )
 
+var lsProject string
+
 var lsCmd = &cobra.Command{
 	Use:     "ls",
 	Aliases: []string{"addition"},
 	Short:   "list all apps",
-	Long:    "show all the apps in the caprover instance",
+	Long: `show all the apps in the caprover instance
+
+With --project, only the apps of a project and of the projects nested in it
+are shown. Nested projects are written as paths, e.g. shop/payments.
+
+With --selector, only the apps whose labels match it are shown.`,
+	Example: "letgofur ls\nletgofur ls --project shop\nletgofur ls -l team=payments,tier!=1",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		appDetails, err := captain.GetAppDetails()
+		client := clientFrom(cmd)
+
+		sel, err := workspace.ParseSelector(selector)
+		if err != nil {
+			return err
+		}
+
+		appDetails, err := client.GetAppDetails()
 		if err != nil {
 			return fmt.Errorf("error getting app details: %w", err)
 		}
+
+		var projects []crapi.ProjectDefinition
+		if lsProject != "" {
+			if projects, err = client.GetProjects(); err != nil {
+				return fmt.Errorf("error getting projects: %w", err)
+			}
+			if _, ok := crapi.FindProject(projects, lsProject); !ok {
+				return fmt.Errorf("project %s not found", lsProject)
+			}
+		}
+
 		for _, app := range appDetails.Data.AppDefinitions {
+			if lsProject != "" && !inProject(crapi.ProjectPath(projects, app.ProjectID), lsProject) {
+				continue
+			}
+			if !sel.Matches(crapi.Labels(app.Tags)) {
+				continue
+			}
 			fmt.Println("- " + app.AppName)
 		}
 		return nil
 	},
 }
+
+// inProject reports whether the project at path is project or nested in it.
+func inProject(path string, project string) bool {
+	project = strings.Trim(project, "/")
+	return path == project || strings.HasPrefix(path, project+"/")
+}
+
+func init() {
+	lsCmd.Flags().StringVar(&lsProject, "project", "", "Only list the apps of this project")
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsFollow     bool
+	logsTail       int
+	logsSince      time.Duration
+	logsGrep       string
+	logsTimestamps bool
+)
+
+// logsPollInterval is how often logs are fetched again with --follow.
+var logsPollInterval = 2 * time.Second
+
+// prefixColors are the ANSI colors used to tell apps apart.
+var prefixColors = []string{"36", "33", "35", "32", "34", "31"}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <app...> | -l <selector>",
+	Short: "Show the runtime logs of apps",
+	Long: `Show the runtime logs of one or more apps.
+
+Lines of several apps are interleaved by time and prefixed with the app name.
+With --follow, the logs are fetched again every few seconds and only new lines
+are printed.`,
+	Example: "letgofur logs my-app --tail 100\nletgofur logs api worker --follow --since 10m --grep ERROR\nletgofur logs -l team=payments --follow",
+	Args:    cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		args, err := selectApps(client, args)
+		if err != nil {
+			return err
+		}
+
+		var grep *regexp.Regexp
+		if logsGrep != "" {
+			if grep, err = regexp.Compile(logsGrep); err != nil {
+				return fmt.Errorf("invalid --grep pattern: %w", err)
+			}
+		}
+
+		var since time.Time
+		if logsSince > 0 {
+			since = time.Now().Add(-logsSince)
+		}
+
+		printer := newLogPrinter(args)
+		seen := make(map[string][]string, len(args))
+
+		for first := true; ; first = false {
+			var batch []appLogLine
+			for _, appName := range args {
+				raw, err := client.GetAppLogs(appName)
+				if err != nil {
+					return fmt.Errorf("error getting logs of app '%s': %w", appName, err)
+				}
+
+				lines := crapi.SplitAppLogs(raw)
+				fresh := newAppLogLines(seen[appName], lines)
+				seen[appName] = rawLines(lines)
+
+				fresh = filterLogLines(fresh, since, grep)
+				if first && logsTail > 0 && len(fresh) > logsTail {
+					fresh = fresh[len(fresh)-logsTail:]
+				}
+
+				// lines without a time, like the rest of a stack trace, are
+				// sorted with the line before them
+				var at time.Time
+				for _, line := range fresh {
+					if !line.Time.IsZero() {
+						at = line.Time
+					}
+					batch = append(batch, appLogLine{app: appName, line: line, at: at})
+				}
+			}
+
+			// interleave apps by time, keeping the order of each app's lines
+			sort.SliceStable(batch, func(i, j int) bool {
+				return batch[i].at.Before(batch[j].at)
+			})
+			for _, l := range batch {
+				printer.print(l)
+			}
+
+			if !logsFollow {
+				return nil
+			}
+
+			time.Sleep(logsPollInterval)
+		}
+	},
+}
+
+// appLogLine is a log line of a given app.
+type appLogLine struct {
+	app  string
+	line crapi.LogLine
+	// at is the time the line is sorted by
+	at time.Time
+}
+
+// newAppLogLines returns the lines that weren't part of the previous fetch.
+func newAppLogLines(prev []string, lines []crapi.LogLine) []crapi.LogLine {
+	fresh := crapi.NewLogLines(prev, rawLines(lines))
+	return lines[len(lines)-len(fresh):]
+}
+
+func rawLines(lines []crapi.LogLine) []string {
+	raw := make([]string, len(lines))
+	for i, line := range lines {
+		raw[i] = line.Raw
+	}
+
+	return raw
+}
+
+// filterLogLines keeps the lines received after since that match grep. Lines
+// without a timestamp are kept by the since filter.
+func filterLogLines(lines []crapi.LogLine, since time.Time, grep *regexp.Regexp) []crapi.LogLine {
+	var kept []crapi.LogLine
+	for _, line := range lines {
+		if !since.IsZero() && !line.Time.IsZero() && line.Time.Before(since) {
+			continue
+		}
+		if grep != nil && !grep.MatchString(line.Text) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return kept
+}
+
+// logPrinter prints log lines, prefixed with their app name when logs of
+// several apps are shown.
+type logPrinter struct {
+	mu       sync.Mutex
+	prefixes map[string]string
+}
+
+func newLogPrinter(apps []string) *logPrinter {
+	p := &logPrinter{prefixes: map[string]string{}}
+	if len(apps) < 2 {
+		return p
+	}
+
+	width := 0
+	for _, app := range apps {
+		width = max(width, len(app))
+	}
+
+	color := useColor()
+	for i, app := range apps {
+		prefix := fmt.Sprintf("%-*s | ", width, app)
+		if color {
+			prefix = fmt.Sprintf("\x1b[%sm%s\x1b[0m", prefixColors[i%len(prefixColors)], prefix)
+		}
+		p.prefixes[app] = prefix
+	}
+
+	return p
+}
+
+func (p *logPrinter) print(l appLogLine) {
+	text := l.line.Text
+	if logsTimestamps {
+		text = l.line.Raw
+	}
+
+	p.printLine(l.app, text)
+}
+
+// printLine prints a line of an app. It is safe for concurrent use.
+func (p *logPrinter) printLine(app string, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Println(p.prefixes[app] + text)
+}
+
+// useColor reports whether stdout is a terminal and colors aren't disabled
+// with NO_COLOR.
+func useColor() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0 && !strings.EqualFold(os.Getenv("TERM"), "dumb")
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep fetching and print new lines")
+	logsCmd.Flags().IntVarP(&logsTail, "tail", "n", 0, "Only show the last N lines of each app, 0 for all")
+	logsCmd.Flags().DurationVar(&logsSince, "since", 0, "Only show lines newer than this, e.g. 10m or 2h")
+	logsCmd.Flags().StringVar(&logsGrep, "grep", "", "Only show lines matching this regular expression")
+	logsCmd.Flags().BoolVarP(&logsTimestamps, "timestamps", "t", false, "Show the timestamp of every line")
+
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pararang/letgofur/workspace"
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [config-file...] | -l <selector>",
+	Short: "Show what apply would change",
+	Long: `Show what apply would change for the apps described by configuration files,
+without changing anything. Templates are shown as unified diffs.`,
+	Example: "letgofur plan ./captain-example-com/myapp.yml\nletgofur plan ./captain-example-com/*.yml\nletgofur plan -l team=payments",
+	Args:    cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		files, err := selectConfigFiles(client, args)
+		if err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, configFile := range files {
+			changed, err := planConfigFile(client, configFile)
+			if err != nil {
+				return fmt.Errorf("%s: %w", configFile, err)
+			}
+			if changed {
+				pending++
+			}
+		}
+
+		if len(files) > 1 {
+			fmt.Printf("\n%d of %d app(s) to change.\n", pending, len(files))
+		}
+		return nil
+	},
+}
+
+// planConfigFile prints the changes apply would make for a configuration file
+// and reports whether there are any.
+func planConfigFile(client Client, configFile string) (bool, error) {
+	config, err := workspace.Load(configFile)
+	if err != nil {
+		return false, err
+	}
+
+	changes, warnings, err := workspace.Plan(client, config)
+	if err != nil {
+		return false, err
+	}
+	for _, warning := range warnings {
+		fmt.Printf("Warning: app '%s': %s\n", config.AppName, warning)
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("App '%s' is up to date.\n", config.AppName)
+		return false, nil
+	}
+
+	fmt.Printf("App '%s' will be updated:\n", config.AppName)
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+
+		diff := unifiedDiff(change.Field+" (CapRover)", change.Field+" (workspace)", change.Old, change.New)
+		for _, line := range strings.SplitAfter(diff, "\n") {
+			if line != "" {
+				fmt.Printf("    %s", line)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+}
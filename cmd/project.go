@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var projectDescription string
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage the projects apps are grouped in",
+	Long: `Manage the projects apps are grouped in.
+
+Nested projects are written as paths, e.g. shop/payments. Apps are moved
+between projects with the Project setting of their configuration file.`,
+}
+
+var projectLsCmd = &cobra.Command{
+	Use:     "ls",
+	Short:   "List the projects and the number of apps in each",
+	Example: "letgofur project ls",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+
+		projects, err := client.GetProjects()
+		if err != nil {
+			return fmt.Errorf("error getting projects: %w", err)
+		}
+		appDetails, err := client.GetAppDetails()
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+
+		apps := map[string]int{}
+		for _, app := range appDetails.Data.AppDefinitions {
+			apps[app.ProjectID]++
+		}
+
+		sort.Slice(projects, func(i, j int) bool {
+			return crapi.ProjectPath(projects, projects[i].ID) < crapi.ProjectPath(projects, projects[j].ID)
+		})
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROJECT\tAPPS\tDESCRIPTION")
+		for _, p := range projects {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", crapi.ProjectPath(projects, p.ID), apps[p.ID], orDash(p.Description))
+		}
+		return w.Flush()
+	},
+}
+
+var projectCreateCmd = &cobra.Command{
+	Use:     "create <project>",
+	Short:   "Create a project, nested in another one when given a path",
+	Example: "letgofur project create shop\nletgofur project create shop/payments --description \"Payment services\"",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		project := strings.Trim(args[0], "/")
+
+		projects, err := client.GetProjects()
+		if err != nil {
+			return fmt.Errorf("error getting projects: %w", err)
+		}
+		if _, ok := crapi.FindProject(projects, project); ok {
+			return fmt.Errorf("project %s already exists", project)
+		}
+
+		parentID := ""
+		if parent := path.Dir(project); parent != "." {
+			p, ok := crapi.FindProject(projects, parent)
+			if !ok {
+				return fmt.Errorf("parent project %s not found", parent)
+			}
+			parentID = p.ID
+		}
+
+		if err := client.CreateProject(path.Base(project), parentID, projectDescription); err != nil {
+			return fmt.Errorf("error creating project %s: %w", project, err)
+		}
+		fmt.Printf("Project '%s' created.\n", project)
+
+		return nil
+	},
+}
+
+var projectRmCmd = &cobra.Command{
+	Use:     "rm <project...>",
+	Aliases: []string{"delete"},
+	Short:   "Delete empty projects",
+	Example: "letgofur project rm shop/payments",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+
+		projects, err := client.GetProjects()
+		if err != nil {
+			return fmt.Errorf("error getting projects: %w", err)
+		}
+
+		var errs []error
+		for _, project := range args {
+			p, ok := crapi.FindProject(projects, project)
+			if !ok {
+				errs = append(errs, fmt.Errorf("project %s not found", project))
+				continue
+			}
+			if err := client.DeleteProjects(p.ID); err != nil {
+				errs = append(errs, fmt.Errorf("error deleting project %s: %w", project, err))
+				continue
+			}
+			fmt.Printf("Project '%s' deleted.\n", project)
+		}
+
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	projectCreateCmd.Flags().StringVar(&projectDescription, "description", "", "Description of the project")
+
+	projectCmd.AddCommand(projectLsCmd, projectCreateCmd, projectRmCmd)
+	rootCmd.AddCommand(projectCmd)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/spf13/cobra"
+)
+
+var rollbackTo int
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <app>",
+	Short: "Redeploy a previous version of an app",
+	Long: `Redeploy the image of a previous version of an app, the version before the
+running one by default, and wait for it to become active.
+
+The rollback is a deploy of its own: it shows up as a new version in the history.
+Rolling back again goes further back, skipping the versions rolled back from.`,
+	Example: "letgofur rollback my-app\nletgofur rollback my-app --to 12",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		app, err := client.GetAppDetailFor(appName)
+		if err != nil {
+			return fmt.Errorf("error getting app details: %w", err)
+		}
+
+		target, err := rollbackTarget(app, cmd.Flags().Changed("to"), rollbackTo)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Rolling back app '%s' from version %d to version %d...\n", appName, app.DeployedVersion, target.Version)
+		return deployImageTo(client, appName, target.DeployedImageName)
+	},
+}
+
+// rollbackTarget picks the version to roll back to: the given one, or else
+// the newest version older than the first one running the current image.
+// Since a rollback deploys an old image as a new version, the versions between
+// the image it redeployed and the rollback itself are the ones rolled back
+// from, and are skipped too.
+func rollbackTarget(app crapi.AppDefinition, explicit bool, version int) (crapi.AppVersion, error) {
+	if explicit {
+		for _, v := range app.Versions {
+			if v.Version != version {
+				continue
+			}
+			if v.Version == app.DeployedVersion {
+				return crapi.AppVersion{}, fmt.Errorf("app '%s' is already running version %d", app.AppName, v.Version)
+			}
+			if v.DeployedImageName == "" {
+				return crapi.AppVersion{}, fmt.Errorf("version %d of app '%s' has no image to redeploy", v.Version, app.AppName)
+			}
+			return v, nil
+		}
+		return crapi.AppVersion{}, fmt.Errorf("app '%s' has no version %d, see 'letgofur history %s'", app.AppName, version, app.AppName)
+	}
+
+	running := ""
+	for _, v := range app.Versions {
+		if v.Version == app.DeployedVersion {
+			running = v.DeployedImageName
+		}
+	}
+
+	origin := app.DeployedVersion
+	for _, v := range app.Versions {
+		if running != "" && v.DeployedImageName == running && v.Version < origin {
+			origin = v.Version
+		}
+	}
+
+	var target *crapi.AppVersion
+	for i, v := range app.Versions {
+		if v.Version < origin && v.DeployedImageName != "" && v.DeployedImageName != running && (target == nil || v.Version > target.Version) {
+			target = &app.Versions[i]
+		}
+	}
+	if target == nil {
+		return crapi.AppVersion{}, fmt.Errorf("app '%s' has no version before %d to roll back to", app.AppName, origin)
+	}
+
+	return *target, nil
+}
+
+func init() {
+	rollbackCmd.Flags().IntVar(&rollbackTo, "to", 0, "The version to roll back to, see the history command")
+	rollbackCmd.Flags().DurationVar(&deployTimeout, "timeout", 15*time.Minute, "How long to wait for the version to become active")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -1,18 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 
 	"github.com/pararang/letgofur/crapi"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	host    string
-	passwd  string
-	captain *crapi.Caprover
+	host   string
+	passwd string
 )
 
 var rootCmd = &cobra.Command{
@@ -20,6 +22,25 @@ var rootCmd = &cobra.Command{
 	Short: "letgofur is a cli tool for caprover",
 	Long:  "letgofur (letnan golang) is a cli tool for accessing caprover instances",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if clientFrom(cmd) != nil {
+			return nil
+		}
+
+		if deployAppToken != "" {
+			// least privilege: the deploy token is used even with --passwd
+			if host == "" {
+				return fmt.Errorf("--host is required")
+			}
+
+			capInstance, err := crapi.NewAppTokenInstance(host, deployAppToken)
+			if err != nil {
+				return fmt.Errorf("error creating Caprover instance: %w", err)
+			}
+
+			cmd.SetContext(WithClient(cmd.Context(), &capInstance))
+			return nil
+		}
+
 		if host == "" || passwd == "" {
 			return fmt.Errorf("both --host and --passwd are required")
 		}
@@ -29,21 +50,20 @@ var rootCmd = &cobra.Command{
 			return fmt.Errorf("error creating Caprover instance: %w", err)
 		}
 
-		captain = &capInstance
+		cmd.SetContext(WithClient(cmd.Context(), &capInstance))
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Welcome, Leutenant Gofurr!")
-		fmt.Println("Connected to the Captain at:", host)
+		fmt.Println("Connected to the Captain at:", clientFrom(cmd).BaseURL())
 	},
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&host, "host", "", "The host to connect to")
-	rootCmd.MarkPersistentFlagRequired("host")
-
+	// --host and --passwd are checked in PersistentPreRunE, they aren't needed
+	// when a client is injected with WithClient
+	rootCmd.PersistentFlags().StringVar(&host, "host", "", "The host to connect to, e.g. captain.your.domain or your.domain")
 	rootCmd.PersistentFlags().StringVar(&passwd, "passwd", "", "The password to connect to the host")
-	rootCmd.MarkPersistentFlagRequired("passwd")
 
 	initWorkspace.Flags().BoolVar(&initGit, "git", false, "Initialize a git repository in the generated workspace")
 
@@ -52,12 +72,46 @@ func init() {
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	if err := ExecuteContext(context.Background()); err != nil {
 		fmt.Fprintf(os.Stderr, "Oops. An error while executing letnan '%s'\n", err)
 		os.Exit(1)
 	}
 }
 
+// ExecuteContext runs the command line with ctx, which may carry a client
+// injected with WithClient.
+func ExecuteContext(ctx context.Context) error {
+	// cobra keeps the context and flag values of the previous run on
+	// commands, so drop them for every run to start from ctx and the defaults
+	reset(rootCmd)
+	return rootCmd.ExecuteContext(ctx)
+}
+
+func reset(cmd *cobra.Command) {
+	cmd.SetContext(nil)
+	cmd.Flags().VisitAll(resetFlag)
+	for _, sub := range cmd.Commands() {
+		reset(sub)
+	}
+}
+
+func resetFlag(f *pflag.Flag) {
+	if !f.Changed {
+		return
+	}
+
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		var def []string
+		if trimmed := strings.Trim(f.DefValue, "[]"); trimmed != "" {
+			def = strings.Split(trimmed, ",")
+		}
+		sv.Replace(def)
+	} else {
+		f.Value.Set(f.DefValue)
+	}
+	f.Changed = false
+}
+
 func isInternalHost(input string) bool {
 	pattern := `^srv-captain--([a-zA-Z0-9-]+)$`
 	re := regexp.MustCompile(pattern)
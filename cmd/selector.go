@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/workspace"
+	"github.com/spf13/cobra"
+)
+
+// selector is the value of the -l/--selector flag shared by the commands
+// working on several apps.
+var selector string
+
+func init() {
+	addSelectorFlag(lsCmd, updateAppCmd, planCmd, validateCmd, restartCmd, scaleCmd, logsCmd, buildCmd, appDeleteCmd)
+}
+
+func addSelectorFlag(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.Flags().StringVarP(&selector, "selector", "l", "", "Select apps by label instead of by name, e.g. team=payments,tier!=1")
+	}
+}
+
+// selectApps returns the apps named in args, or with --selector the apps
+// whose labels match it.
+func selectApps(client Client, args []string) ([]string, error) {
+	if selector == "" {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("app names or --selector required")
+		}
+		return args, nil
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("give either app names or --selector, not both")
+	}
+
+	sel, err := workspace.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	appDetails, err := client.GetAppDetails()
+	if err != nil {
+		return nil, fmt.Errorf("error getting app details: %w", err)
+	}
+
+	var apps []string
+	for _, app := range appDetails.Data.AppDefinitions {
+		if sel.Matches(crapi.Labels(app.Tags)) {
+			apps = append(apps, app.AppName)
+		}
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no app matches the selector %s", selector)
+	}
+
+	return apps, nil
+}
+
+// selectConfigFiles returns the configuration files in args, or with
+// --selector the ones whose labels match it. Without files, --selector
+// searches the workspace of client, or the current directory when client is
+// nil or there's no workspace.
+func selectConfigFiles(client Client, args []string) ([]string, error) {
+	if selector == "" {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("configuration files or --selector required")
+		}
+		return args, nil
+	}
+
+	sel, err := workspace.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if len(args) == 0 {
+		dir := "."
+		if client != nil {
+			if ws, ok := currentWorkspace(client); ok {
+				dir = ws
+			}
+		}
+		walkAppConfigs(dir, func(path string, config workspace.AppConfig) bool {
+			if sel.Matches(config.Labels) {
+				files = append(files, path)
+			}
+			return true
+		})
+	} else {
+		for _, file := range args {
+			config, err := workspace.Load(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if sel.Matches(config.Labels) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no configuration file matches the selector %s", selector)
+	}
+	return files, nil
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	limitMemory string
+	limitCPU    float64
+)
+
+var restartCmd = &cobra.Command{
+	Use:     "restart <app...> | -l <selector>",
+	Short:   "Restart apps",
+	Example: "letgofur restart my-app\nletgofur restart api worker\nletgofur restart -l team=payments",
+	Args:    cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		args, err := selectApps(client, args)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, appName := range args {
+			if err := client.RestartApp(appName); err != nil {
+				errs = append(errs, fmt.Errorf("error restarting app '%s': %w", appName, err))
+				continue
+			}
+			fmt.Printf("App '%s' restarted.\n", appName)
+		}
+
+		return errors.Join(errs...)
+	},
+}
+
+var scaleCmd = &cobra.Command{
+	Use:     "scale <app> <instances> | -l <selector> <instances>",
+	Short:   "Set the number of instances of an app",
+	Example: "letgofur scale my-app 3\nletgofur scale my-app 0\nletgofur scale -l team=payments 2",
+	Args:    cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+
+		count := args[len(args)-1]
+		instances, err := strconv.Atoi(count)
+		if err != nil || instances < 0 {
+			return fmt.Errorf("invalid number of instances: %s", count)
+		}
+
+		apps, err := selectApps(client, args[:len(args)-1])
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, appName := range apps {
+			if err := client.UpdateInstanceCount(appName, instances); err != nil {
+				errs = append(errs, fmt.Errorf("error scaling app '%s': %w", appName, err))
+				continue
+			}
+			fmt.Printf("App '%s' scaled to %d instance(s).\n", appName, instances)
+
+			if err := syncWorkspace(client, appName, instances, "Instances"); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	},
+}
+
+var setPortCmd = &cobra.Command{
+	Use:     "set-port <app> <port>",
+	Short:   "Set the HTTP port the container of an app listens on",
+	Example: "letgofur set-port my-app 3000",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		port, err := strconv.Atoi(args[1])
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port: %s", args[1])
+		}
+
+		if err := client.UpdateContainerHTTPPort(appName, port); err != nil {
+			return fmt.Errorf("error setting the port of app '%s': %w", appName, err)
+		}
+		fmt.Printf("App '%s' now receives HTTP traffic on port %d.\n", appName, port)
+
+		return syncWorkspace(client, appName, port, "ContainerHTTPPort")
+	},
+}
+
+var websocketCmd = &cobra.Command{
+	Use:       "websocket <app> on|off",
+	Short:     "Turn websocket support of an app on or off",
+	Example:   "letgofur websocket my-app on",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{"on", "off"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		return toggle(client, args, "websocket support", "WebsocketSupport", client.EnableWebsocketSupport, client.DisableWebsocketSupport)
+	},
+}
+
+var forceHTTPSCmd = &cobra.Command{
+	Use:       "force-https <app> on|off",
+	Short:     "Turn redirection of HTTP to HTTPS for an app on or off",
+	Example:   "letgofur force-https my-app on",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{"on", "off"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		return toggle(client, args, "forced HTTPS", "ForceSsl", client.EnableForceHTTPS, client.DisableForceHTTPS)
+	},
+}
+
+var limitCmd = &cobra.Command{
+	Use:   "limit <app>",
+	Short: "Set the memory and CPU limits of an app",
+	Long: `Set the memory and CPU limits of an app. Only the given limits are changed, 0
+removes a limit.
+
+Memory is in megabytes, or with a K, M or G suffix, e.g. 512M or 1.5G. CPU is a
+number of cores, e.g. 0.5.`,
+	Example: "letgofur limit my-app --memory 512M --cpu 0.5",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := clientFrom(cmd)
+		appName := args[0]
+
+		memoryChanged, cpuChanged := cmd.Flags().Changed("memory"), cmd.Flags().Changed("cpu")
+		if !memoryChanged && !cpuChanged {
+			return fmt.Errorf("set --memory, --cpu or both")
+		}
+
+		var limits crapi.ResourceLimits
+		if memoryChanged {
+			memory, err := parseMemory(limitMemory)
+			if err != nil {
+				return err
+			}
+			limits.MemoryBytes = &memory
+		}
+		if cpuChanged {
+			if limitCPU < 0 {
+				return fmt.Errorf("invalid --cpu: %g", limitCPU)
+			}
+			cpus := crapi.NanoCPUs(limitCPU)
+			limits.NanoCPUs = &cpus
+		}
+
+		// only the limits are changed, the rest of the override is kept
+		if err := client.UpdateResourceLimits(appName, limits); err != nil {
+			return fmt.Errorf("error setting the limits of app '%s': %w", appName, err)
+		}
+		fmt.Printf("Limits of app '%s' updated.\n", appName)
+
+		// a failed memory sync doesn't keep the CPU limit from being synced
+		var errs []error
+		if memoryChanged {
+			errs = append(errs, syncWorkspace(client, appName, crapi.LimitValue(*limits.MemoryBytes), "Resources", "Limits", "MemoryBytes"))
+		}
+		if cpuChanged {
+			errs = append(errs, syncWorkspace(client, appName, crapi.LimitValue(*limits.NanoCPUs), "Resources", "Limits", "NanoCPUs"))
+		}
+		return errors.Join(errs...)
+	},
+}
+
+// toggle turns a boolean setting on or off, as asked by the arguments
+// <app> on|off.
+func toggle(client Client, args []string, name string, field string, enable, disable func(appName string) error) error {
+	appName := args[0]
+
+	var on bool
+	set := disable
+	switch args[1] {
+	case "on":
+		on, set = true, enable
+	case "off":
+	default:
+		return fmt.Errorf("expected on or off, got %s", args[1])
+	}
+
+	if err := set(appName); err != nil {
+		return fmt.Errorf("error turning %s %s for app '%s': %w", name, args[1], appName, err)
+	}
+	fmt.Printf("Turned %s %s for app '%s'.\n", name, args[1], appName)
+
+	return syncWorkspace(client, appName, on, field)
+}
+
+// syncWorkspace sets a setting in the workspace file of an app, when there is
+// one, so the workspace keeps matching the app.
+func syncWorkspace(client Client, appName string, value any, path ...string) error {
+	file, ok := workspaceConfig(client, appName)
+	if !ok {
+		return nil
+	}
+
+	return setWorkspaceValue(file, value, path...)
+}
+
+// workspaceConfig returns the workspace file of an app, when there is one.
+func workspaceConfig(client Client, appName string) (string, bool) {
+	dir, ok := currentWorkspace(client)
+	if !ok {
+		return "", false
+	}
+
+	return findAppConfig(dir, appName)
+}
+
+// setWorkspaceValue sets a setting in a workspace file.
+func setWorkspaceValue(file string, value any, path ...string) error {
+	if err := workspace.SetValue(file, value, path...); err != nil {
+		return fmt.Errorf("error updating the workspace: %w", err)
+	}
+	fmt.Printf("Updated %s in '%s'.\n", strings.Join(path, "."), file)
+	return nil
+}
+
+// parseMemory parses a memory size in megabytes, or with a K, M or G suffix.
+func parseMemory(s string) (int64, error) {
+	units := map[string]int64{"K": 1024, "M": crapi.ResourceOneMb, "G": 1024 * crapi.ResourceOneMb}
+
+	value := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	value = strings.TrimSuffix(value, "I")
+	unit := crapi.ResourceOneMb
+	if n := len(value); n > 0 {
+		if u, ok := units[value[n-1:]]; ok {
+			unit = u
+			value = value[:n-1]
+		}
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid memory size: %s", s)
+	}
+
+	return int64(f * float64(unit)), nil
+}
+
+func init() {
+	limitCmd.Flags().StringVar(&limitMemory, "memory", "", "Memory limit, e.g. 512M or 1G, 0 for none")
+	limitCmd.Flags().Float64Var(&limitCPU, "cpu", 0, "CPU limit in cores, e.g. 0.5, 0 for none")
+
+	rootCmd.AddCommand(restartCmd, scaleCmd, setPortCmd, websocketCmd, forceHTTPSCmd, limitCmd)
+}
@@ -2,95 +2,58 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
+	"github.com/pararang/letgofur/workspace"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var updateAppCmd = &cobra.Command{
-	Use:     "apply [config-file]",
-	Short:   "Update app resources and instances based on configuration file",
-	Long:    "Update app resources and instances based on the YAML configuration file generated by the init command",
-	Example: "letgofur update ./captain-example-com/myapp.yml",
+	Use:     "apply [config-file...] | -l <selector>",
+	Short:   "Update app resources and instances based on configuration files",
+	Long:    "Update app resources and instances based on the YAML configuration files generated by the init command",
+	Example: "letgofur apply ./captain-example-com/myapp.yml\nletgofur apply ./captain-example-com/*.yml\nletgofur apply -l team=payments",
 	Aliases: []string{"apply", "up"},
-	Args:    cobra.ExactArgs(1),
+	Args:    cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		configFile := args[0]
-
-		// Check if file exists
-		if _, err := os.Stat(configFile); os.IsNotExist(err) {
-			return fmt.Errorf("configuration file not found: %s", configFile)
-		}
-
-		// Read the configuration file
-		yamlData, err := os.ReadFile(configFile)
+		client := clientFrom(cmd)
+		files, err := selectConfigFiles(client, args)
 		if err != nil {
-			return fmt.Errorf("error reading configuration file: %w", err)
-		}
-
-		// Parse the YAML configuration
-		var config AppConfig
-		if err := yaml.Unmarshal(yamlData, &config); err != nil {
-			return fmt.Errorf("error parsing YAML configuration: %w", err)
-		}
-
-		// Validate the configuration
-		if config.AppName == "" {
-			return fmt.Errorf("invalid configuration: AppName is required")
+			return err
 		}
-
-		fmt.Printf("Updating app '%s'...\n", config.AppName)
-
-		// Get the current app configuration then override it with the new one defined in the config file
-		currentConfig, err := captain.GetDefaultUpdateRequest(config.AppName)
-		if err != nil {
-			return fmt.Errorf("error getting current app configuration: %w", err)
-		}
-
-		// Update instance count
-		if config.Instances > 0 {
-			fmt.Printf("Setting instance count to %d...\n", config.Instances)
-			currentConfig.InstanceCount = config.Instances
-		}
-
-		if hasResourceConstraints(&config.Resources) {
-			fmt.Println("Updating resource constraints...")
-
-			suo := ServiceUpdateOverride{
-				TaskTemplate: TaskTemplate{
-					Resources: config.Resources,
-				},
+		for _, configFile := range files {
+			if err := applyConfigFile(client, configFile); err != nil {
+				return fmt.Errorf("%s: %w", configFile, err)
 			}
-
-			suoBytes, err := yaml.Marshal(suo)
-			if err != nil {
-				return fmt.Errorf("error marshaling resource constraints: %w", err)
-			}
-
-			currentConfig.ServiceUpdateOverride = string(suoBytes)
 		}
+		return nil
+	},
+}
+
+// applyConfigFile updates the app described by a single configuration file
+func applyConfigFile(client Client, configFile string) error {
+	config, err := workspace.Load(configFile)
+	if err != nil {
+		return err
+	}
 
-		// TODO: ovverride other fields like EnvironmentVariables, BuildOptions, etc.
+	fmt.Printf("Updating app '%s'...\n", config.AppName)
 
-		err = captain.UpdateConfig(currentConfig)
-		if err != nil {
-			return fmt.Errorf("error updating app configuration: %w", err)
-		}
+	changes, err := workspace.Apply(client, config)
+	if err != nil {
+		return err
+	}
 
-		fmt.Printf("App '%s' updated successfully!\n", config.AppName)
+	if len(changes) == 0 {
+		fmt.Printf("App '%s' is up to date.\n", config.AppName)
 		return nil
-	},
-}
+	}
 
-// hasResourceConstraints checks if the Resources structure has any constraints defined
-func hasResourceConstraints(res *Resources) bool {
-	if res == nil {
-		return false
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
 	}
 
-	return res.Limits.MemoryBytes != nil || res.Limits.NanoCPUs != nil ||
-		res.Reservations.MemoryBytes != nil || res.Reservations.NanoCPUs != nil
+	fmt.Printf("App '%s' updated successfully!\n", config.AppName)
+	return nil
 }
 
 func init() {
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pararang/letgofur/workspace"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [config-file...] | -l <selector>",
+	Short: "Check configuration files without connecting to CapRover",
+	Long: `Check configuration files and the files they reference without connecting
+to CapRover, e.g. in a pre-commit hook or CI.
+
+Pre-deploy scripts are syntax-checked: brackets, strings and comments must be
+closed and preDeployFunction must be declared with its two parameters.`,
+	Example: "letgofur validate ./captain-example-com/*.yml",
+	Args:    cobra.ArbitraryArgs,
+	// no --host or --passwd needed
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := selectConfigFiles(nil, args)
+		if err != nil {
+			return err
+		}
+
+		invalid := 0
+		for _, configFile := range files {
+			config, err := workspace.Load(configFile)
+			if err == nil {
+				err = workspace.Validate(config)
+			}
+			if err != nil {
+				invalid++
+				fmt.Printf("%s: %v\n", configFile, err)
+				continue
+			}
+			fmt.Printf("%s: ok\n", configFile)
+		}
+
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d configuration file(s) are invalid", invalid, len(files))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
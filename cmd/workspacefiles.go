@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/workspace"
+)
+
+// workspaceDirName is the name of the directory init creates for the
+// instance client is connected to.
+func workspaceDirName(client Client) string {
+	return strings.ReplaceAll(client.Hostname(), ".", "-")
+}
+
+// currentWorkspace returns the workspace directory of the instance client is
+// connected to, when the current directory is inside it or is its parent.
+func currentWorkspace(client Client) (string, bool) {
+	name := workspaceDirName(client)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == name {
+			return dir, true
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	dir := filepath.Join(cwd, name)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir, true
+	}
+
+	return "", false
+}
+
+// projectDir returns the directory of a project in a workspace, one
+// directory per level of nesting. Project names that aren't a single clean
+// path element, like ".." or names with a slash, are rejected: they would
+// write outside of the workspace or be read back as another project.
+func projectDir(workspaceDir string, projects []crapi.ProjectDefinition, projectID string) (string, error) {
+	dir := workspaceDir
+	for _, name := range crapi.ProjectNames(projects, projectID) {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || !filepath.IsLocal(name) {
+			return "", fmt.Errorf("project name %q can't be used as a directory", name)
+		}
+		dir = filepath.Join(dir, name)
+	}
+
+	return dir, nil
+}
+
+// appConfigFile returns the path of the configuration file of an app in a
+// workspace, in the directory of its project, and the path of the project,
+// empty when the app has none.
+func appConfigFile(workspaceDir string, projects []crapi.ProjectDefinition, app crapi.AppDefinition) (string, string, error) {
+	dir := workspaceDir
+	project := crapi.ProjectPath(projects, app.ProjectID)
+	if project != "" {
+		var err error
+		if dir, err = projectDir(workspaceDir, projects, app.ProjectID); err != nil {
+			return "", "", err
+		}
+	}
+
+	return filepath.Join(dir, app.AppName+".yml"), project, nil
+}
+
+// removeAppFiles removes the NGINX template and the pre-deploy script
+// referenced by the configuration of a deleted app. Files outside of the
+// workspace, or still referenced by another configuration, are kept.
+func removeAppFiles(workspaceDir string, config workspace.AppConfig) ([]string, error) {
+	var paths []string
+	for _, path := range []string{config.Nginx, config.PreDeploy} {
+		if path == "" || path == workspace.NginxDefault || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+			continue
+		}
+		path = filepath.Join(config.Dir, filepath.FromSlash(path))
+		if rel, err := filepath.Rel(workspaceDir, path); err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	shared := map[string]bool{}
+	walkAppConfigs(workspaceDir, func(_ string, other workspace.AppConfig) bool {
+		if other.AppName == config.AppName {
+			return true
+		}
+		for _, path := range []string{other.Nginx, other.PreDeploy} {
+			if path != "" {
+				shared[filepath.Join(other.Dir, filepath.FromSlash(path))] = true
+			}
+		}
+		return true
+	})
+
+	var removed []string
+	var errs []error
+	for _, path := range paths {
+		if shared[path] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		removed = append(removed, path)
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// moveAppFiles moves the NGINX template and the pre-deploy script referenced
+// with a relative path by a configuration file moved from file to renamed,
+// so that the paths still point to them.
+func moveAppFiles(file string, renamed string) error {
+	from, to := filepath.Dir(file), filepath.Dir(renamed)
+	if from == to {
+		return nil
+	}
+
+	config, err := workspace.Load(renamed)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{config.Nginx, config.PreDeploy} {
+		if path == "" || path == workspace.NginxDefault || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+			continue
+		}
+		path = filepath.FromSlash(path)
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(to, path)), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(from, path), filepath.Join(to, path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findAppConfig returns the configuration file of an app in a workspace.
+// Files that aren't app configurations are skipped.
+func findAppConfig(dir string, appName string) (string, bool) {
+	var found string
+
+	walkAppConfigs(dir, func(path string, config workspace.AppConfig) bool {
+		if config.AppName == appName {
+			found = path
+			return false
+		}
+		return true
+	})
+
+	return found, found != ""
+}
+
+// walkAppConfigs calls fn with every app configuration file under dir until
+// fn returns false. Files that aren't app configurations are skipped.
+func walkAppConfigs(dir string, fn func(path string, config workspace.AppConfig) bool) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if ext := filepath.Ext(path); ext != ".yml" && ext != ".yaml" {
+			return nil
+		}
+
+		if config, err := workspace.Load(path); err == nil && !fn(path, config) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
@@ -24,34 +24,56 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
 	"time"
 )
 
+// Caprover is a client for the CapRover API. Create it with
+// NewCaproverInstance.
+//
+// A Caprover is safe for concurrent use by multiple goroutines once created;
+// Endpoint and Password must not be changed after that. The auth token is
+// guarded internally. When CapRover rejects an expired token, the request is
+// retried after a single re-login that is shared by every goroutine hitting
+// the same expired token. The cached app list is shared as well.
 type Caprover struct {
 	Endpoint string
 	Password string
-	Token    string
+	auth     *authState
 	client   *http.Client
+	apps     *appsCache
+	locks    *appLocks
+	// appToken replaces the password based auth token, see
+	// NewAppTokenInstance
+	appToken string
 }
 
 // NewCaproverInstance (endpoint string, password string) (Caprover, error): This
 // method is a constructor function that creates a new instance of the Caprover
 // struct. It takes an endpoint and password as parameters and initializes the
-// Caprover struct with the provided values. It also calls the Login method
-// internally to authenticate with the Caprover instance using the provided
-// credentials.
+// Caprover struct with the provided values. The endpoint is normalized with
+// NormalizeEndpoint first. It also calls the Login method internally to
+// authenticate with the Caprover instance using the provided credentials.
 func NewCaproverInstance(endpoint string, password string) (Caprover, error) {
+	endpoint, err := NormalizeEndpoint(endpoint)
+	if err != nil {
+		return Caprover{}, err
+	}
+
 	cp := Caprover{
 		Endpoint: endpoint,
 		Password: password,
-		Token:    "",
+		auth:     newAuthState(),
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		apps:  newAppsCache(),
+		locks: newAppLocks(),
 	}
 
-	err := cp.Login()
+	err = cp.Login()
 	if err != nil {
 		return Caprover{}, err
 	}
@@ -59,26 +81,63 @@ func NewCaproverInstance(endpoint string, password string) (Caprover, error) {
 	return cp, nil
 }
 
+// NewAppTokenInstance creates a client that authenticates with the deploy
+// token of a single app instead of logging in with the password. CapRover
+// only accepts it to deploy that app and to read its build status and logs.
+func NewAppTokenInstance(endpoint string, appToken string) (Caprover, error) {
+	endpoint, err := NormalizeEndpoint(endpoint)
+	if err != nil {
+		return Caprover{}, err
+	}
+
+	if appToken == "" {
+		return Caprover{}, errors.New("app token is empty")
+	}
+
+	return Caprover{
+		Endpoint: endpoint,
+		auth:     newAuthState(),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		apps:     newAppsCache(),
+		locks:    newAppLocks(),
+		appToken: appToken,
+	}, nil
+}
+
 func (c *Caprover) buildURL(path string) string {
 	return c.Endpoint + path
 }
 
-func (c *Caprover) addHeaders(req *http.Request) {
+func (c *Caprover) addHeaders(req *http.Request, token string) {
 	req.Header.Add("Content-Type", "application/json;charset=UTF-8")
 	req.Header.Add("accept", "application/json, text/plain, */*")
 	req.Header.Add("x-namespace", "captain")
 
-	if c.Token != "" {
-		req.Header.Add("x-captain-auth", c.Token)
+	if token != "" {
+		req.Header.Add("x-captain-auth", token)
+	}
+	if c.appToken != "" {
+		req.Header.Add("x-captain-app-token", c.appToken)
 	}
 }
 
 // Login () error: This method authenticates the client with the Caprover
 // instance. It sends a POST request to the Caprover login endpoint with the
 // provided password. If the login is successful, it retrieves and stores the
-// authentication token for subsequent requests.
+// authentication token for subsequent requests. Concurrent calls share a
+// single login request.
 func (c *Caprover) Login() error {
-	fmt.Println("Attempting Login to Caprover Instance")
+	if c.appToken != "" {
+		return errors.New("clients using an app token can't log in")
+	}
+
+	return c.auth.do(c.login)
+}
+
+func (c *Caprover) login() error {
+	fmt.Fprintln(os.Stderr, "Attempting Login to Caprover Instance")
 
 	url := c.buildURL(URLLoginPath)
 
@@ -95,7 +154,7 @@ func (c *Caprover) Login() error {
 		return fmt.Errorf("error creating request: %w", err)
 	}
 
-	c.addHeaders(req)
+	c.addHeaders(req, "")
 
 	res, err := c.client.Do(req)
 	if err != nil {
@@ -117,46 +176,36 @@ func (c *Caprover) Login() error {
 		return fmt.Errorf("error unmarshaling response: %w", err)
 	}
 
-	c.Token = rsp.Data.Token
+	if rsp.Status != StatusOK {
+		return fmt.Errorf("login error: %s", rsp.Description)
+	}
+
+	c.auth.set(rsp.Data.Token)
 	return nil
 }
 
 // GetAppDetails () (ListAppResponse, error): This method retrieves the details
 // of all the applications deployed on the Caprover instance. It sends a GET
 // request to the Caprover app list endpoint and returns the list of applications
-// along with their details.
+// along with their details. The list is served from the client's snapshot
+// cache when no app changed since it was fetched.
 func (c *Caprover) GetAppDetails() (ListAppResponse, error) {
-	fmt.Println("Getting App Details")
-
-	url := c.buildURL(URLAppListPath)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	return c.apps.get("", c.fetchAppDetails)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return ListAppResponse{}, fmt.Errorf("error creating request: %w", err)
-	}
-	
-	c.addHeaders(req)
+// getAppDetailsFor returns the app list, refreshing the snapshot only when
+// appName was changed since it was fetched.
+func (c *Caprover) getAppDetailsFor(appName string) (ListAppResponse, error) {
+	return c.apps.get(appName, c.fetchAppDetails)
+}
 
-	res, err := c.client.Do(req)
-	if err != nil {
-		return ListAppResponse{}, err
-	}
-	defer res.Body.Close()
+// fetchAppDetails downloads the raw app list from the Caprover instance.
+func (c *Caprover) fetchAppDetails() ([]byte, error) {
+	fmt.Fprintln(os.Stderr, "Getting App Details")
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return ListAppResponse{}, fmt.Errorf("error reading response body: %w", err)
-	}
-	
-	var rsp ListAppResponse
-	if err := json.Unmarshal(body, &rsp); err != nil {
-		return ListAppResponse{}, fmt.Errorf("error unmarshaling response: %w", err)
-	}
+	url := c.buildURL(URLAppListPath)
 
-	return rsp, nil
+	return c.doRequest("GET", url, nil, -1)
 }
 
 // GetAppDetailFor (appName string) (AppDefinition, error): This method retrieves the details of
@@ -165,13 +214,16 @@ func (c *Caprover) GetAppDetails() (ListAppResponse, error) {
 // application with the matching name. If found, it returns the application
 // details; otherwise, it returns an error.
 func (c *Caprover) GetAppDetailFor(appName string) (AppDefinition, error) {
-	allDetails, _ := c.GetAppDetails()
+	allDetails, err := c.getAppDetailsFor(appName)
+	if err != nil {
+		return AppDefinition{}, err
+	}
 	for _, v := range allDetails.Data.AppDefinitions {
 		if strings.Compare(appName, v.AppName) == 0 {
 			return v, nil
 		}
 	}
-	return AppDefinition{}, errors.New("not found")
+	return AppDefinition{}, fmt.Errorf("app %s not found", appName)
 }
 
 // GetDefaultUpdateRequest (appName string) (UpdateAppRequest, error): This
@@ -181,7 +233,10 @@ func (c *Caprover) GetAppDetailFor(appName string) (AppDefinition, error) {
 // returns an UpdateAppRequest containing the default values for updating the
 // application; otherwise, it returns an error.
 func (c *Caprover) GetDefaultUpdateRequest(appName string) (UpdateAppRequest, error) {
-	allDetails, _ := c.GetAppDetails()
+	allDetails, err := c.getAppDetailsFor(appName)
+	if err != nil {
+		return UpdateAppRequest{}, err
+	}
 
 	var m AppDefinition
 	var found bool
@@ -194,7 +249,7 @@ func (c *Caprover) GetDefaultUpdateRequest(appName string) (UpdateAppRequest, er
 	}
 
 	if !found {
-		return UpdateAppRequest{}, errors.New("not found")
+		return UpdateAppRequest{}, fmt.Errorf("app %s not found", appName)
 	}
 
 	appRequest := UpdateAppRequest{
@@ -210,12 +265,23 @@ func (c *Caprover) GetDefaultUpdateRequest(appName string) (UpdateAppRequest, er
 			RepoInfo: m.AppPushWebhook.RepoInfo,
 		},
 		NodeID:                m.NodeID,
+		Networks:              m.Networks,
 		PreDeployFunction:     m.PreDeployFunction,
 		ServiceUpdateOverride: m.ServiceUpdateOverride,
+		CustomNginxConfig:     m.CustomNginxConfig,
 		ContainerHTTPPort:     m.ContainerHTTPPort,
 		Description:           m.Description,
 		EnvVars:               m.EnvVars,
 		AppDeployTokenConfig:  m.AppDeployTokenConfig,
+		RedirectDomain:        m.RedirectDomain,
+		ProjectID:             m.ProjectID,
+		Tags:                  m.Tags,
+		Raw:                   m.Raw,
+	}
+	if m.HTTPAuth != nil {
+		// copied so patches don't change the cached definition
+		auth := *m.HTTPAuth
+		appRequest.HTTPAuth = &auth
 	}
 
 	return appRequest, nil
@@ -227,10 +293,12 @@ func (c *Caprover) GetDefaultUpdateRequest(appName string) (UpdateAppRequest, er
 // parameters. If the creation is successful, it returns nil; otherwise, it
 // returns an error.
 func (c *Caprover) CreateApp(appName string, hasPersistentData bool) error {
-	fmt.Println("Attempting to create a new app")
+	fmt.Fprintln(os.Stderr, "Attempting to create a new app")
 
 	url := c.buildURL(URLAppRegisterPath)
 
+	defer c.apps.invalidate(appName)
+
 	data := make(map[string]interface{})
 	data["appName"] = appName
 	data["hasPersistentData"] = hasPersistentData
@@ -239,28 +307,11 @@ func (c *Caprover) CreateApp(appName string, hasPersistentData bool) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	payload := bytes.NewBuffer(jsonEncode)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, payload)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
 
-	c.addHeaders(req)
-
-	res, err := c.client.Do(req)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
-	}
 
 	var rsp GenericAppResponse
 	if err := json.Unmarshal(body, &rsp); err != nil {
@@ -280,36 +331,21 @@ func (c *Caprover) CreateApp(appName string, hasPersistentData bool) error {
 // If the update is successful, it returns nil; otherwise, it returns an error.
 // FOR INTERNAL USE ONLY
 func (c *Caprover) updateAppDetails(data UpdateAppRequest) error {
-	fmt.Println("Attempting to Update App Details")
+	fmt.Fprintln(os.Stderr, "Attempting to Update App Details")
 
 	url := c.buildURL(URLUpdateAppPath)
 
+	defer c.apps.invalidate(data.AppName)
+
 	jsonEncode, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	payload := bytes.NewBuffer(jsonEncode)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, payload)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-
-	c.addHeaders(req)
 
-	res, err := c.client.Do(req)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
-	}
 
 	var rsp GenericAppResponse
 	if err := json.Unmarshal(body, &rsp); err != nil {
@@ -328,30 +364,16 @@ func (c *Caprover) updateAppDetails(data UpdateAppRequest) error {
 // app trigger build endpoint with the provided token parameter. If the build is
 // successful, it returns nil; otherwise, it returns an error.
 func (c *Caprover) ForceBuild(token string) error {
-	fmt.Println("Attempting to Force Build")
+	fmt.Fprintln(os.Stderr, "Attempting to Force Build")
 
-	url := c.buildURL(URLAppTriggerBuild) + "?namespace=captain&token=" + token
+	// the app definition only changes once the build is deployed, which
+	// WaitForVersion checks for, so the cached app list stays valid
+	url := c.PushWebhookURL(token)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-
-	c.addHeaders(req)
-
-	res, err := c.client.Do(req)
+	body, err := c.doRequest("POST", url, nil, -1)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
-	}
 	
 	var rsp GenericAppResponse
 	if err := json.Unmarshal(body, &rsp); err != nil {
@@ -365,43 +387,34 @@ func (c *Caprover) ForceBuild(token string) error {
 	return errors.New(rsp.Description)
 }
 
+// PushWebhookURL returns the URL that triggers a build of the app owning the
+// push webhook token, to be set up as a webhook in GitHub, GitLab and the like.
+func (c *Caprover) PushWebhookURL(token string) string {
+	return c.buildURL(URLAppTriggerBuild) + "?namespace=captain&token=" + url.QueryEscape(token)
+}
+
 // EnableBaseDomainSSL (appName string) error: This method enables SSL on the
 // base domain for an application. It sends a POST request to the Caprover enable
 // base domain SSL endpoint with the provided appName parameter. If the SSL
 // enablement is successful, it returns nil; otherwise, it returns an error.
 func (c *Caprover) EnableBaseDomainSSL(appName string) error {
-	fmt.Println("Attempting to Enable SSL on Base Domain")
+	fmt.Fprintln(os.Stderr, "Attempting to Enable SSL on Base Domain")
 
 	url := c.buildURL(URLEnableBaseDomainSslPath)
 
+	defer c.apps.invalidate(appName)
+
 	data := make(map[string]string)
 	data["appName"] = appName
 	jsonEncode, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	payload := bytes.NewBuffer(jsonEncode)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, payload)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
 
-	c.addHeaders(req)
-
-	res, err := c.client.Do(req)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
-	}
 
 	var rsp GenericAppResponse
 	if err := json.Unmarshal(body, &rsp); err != nil {
@@ -420,10 +433,12 @@ func (c *Caprover) EnableBaseDomainSSL(appName string) error {
 // custom domain endpoint with the provided appName and domain parameters. If the
 // domain addition is successful, it returns nil; otherwise, it returns an error.
 func (c *Caprover) AddCustomDomain(appName string, domain string) error {
-	fmt.Println("Attempting to add a new domain")
+	fmt.Fprintln(os.Stderr, "Attempting to add a new domain")
 
 	url := c.buildURL(URLAddCustomDomainPath)
 
+	defer c.apps.invalidate(appName)
+
 	data := make(map[string]string)
 	data["appName"] = appName
 	data["customDomain"] = domain
@@ -431,27 +446,47 @@ func (c *Caprover) AddCustomDomain(appName string, domain string) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	payload := bytes.NewBuffer(jsonEncode)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, payload)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return err
+	}
+
+	var rsp GenericAppResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
 	}
 
-	c.addHeaders(req)
+	if rsp.Status == 100 {
+		return nil
+	}
 
-	res, err := c.client.Do(req)
+	return errors.New(rsp.Description)
+}
+
+// RemoveCustomDomain (appName string, domain string) error: This method removes
+// a custom domain from an application. It sends a POST request to the Caprover
+// remove custom domain endpoint with the provided appName and domain
+// parameters. If the domain removal is successful, it returns nil; otherwise,
+// it returns an error.
+func (c *Caprover) RemoveCustomDomain(appName string, domain string) error {
+	fmt.Fprintln(os.Stderr, "Attempting to remove a domain")
+
+	url := c.buildURL(URLRemoveCustomDomainPath)
+
+	defer c.apps.invalidate(appName)
+
+	jsonEncode, err := json.Marshal(map[string]string{
+		"appName":      appName,
+		"customDomain": domain,
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
+		return err
 	}
 
 	var rsp GenericAppResponse
@@ -459,23 +494,36 @@ func (c *Caprover) AddCustomDomain(appName string, domain string) error {
 		return fmt.Errorf("error unmarshaling response: %w", err)
 	}
 
-	if rsp.Status == 100 {
+	if rsp.Status == StatusOK {
 		return nil
 	}
 
 	return errors.New(rsp.Description)
 }
 
+// SetRedirectDomain (appName string, domain string) error: This method makes
+// every other domain of an application redirect to domain, which must be one
+// of the application's domains. An empty domain removes the redirect. If the
+// update is successful, it returns nil; otherwise, it returns an error.
+func (c *Caprover) SetRedirectDomain(appName string, domain string) error {
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.RedirectDomain = domain
+		return nil
+	})
+}
+
 // EnableCustomDomainSSL (appName string, domain string) error: This method
 // enables SSL on a custom domain for an application. It sends a POST request to
 // the Caprover enable custom domain SSL endpoint with the provided appName and
 // domain parameters. If the SSL enablement is successful, it returns nil;
 // otherwise, it returns an error.
 func (c *Caprover) EnableCustomDomainSSL(appName string, domain string) error {
-	fmt.Println("Attempting to Enable SSL on Custom Domain")
+	fmt.Fprintln(os.Stderr, "Attempting to Enable SSL on Custom Domain")
 
 	url := c.buildURL(URLEnableCustomDomainSslPath)
 
+	defer c.apps.invalidate(appName)
+
 	data := make(map[string]string)
 	data["appName"] = appName
 	data["customDomain"] = domain
@@ -483,28 +531,11 @@ func (c *Caprover) EnableCustomDomainSSL(appName string, domain string) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	payload := bytes.NewBuffer(jsonEncode)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, payload)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-
-	c.addHeaders(req)
 
-	res, err := c.client.Do(req)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
-	}
 
 	var rsp GenericAppResponse
 	if err := json.Unmarshal(body, &rsp); err != nil {
@@ -518,174 +549,159 @@ func (c *Caprover) EnableCustomDomainSSL(appName string, domain string) error {
 	return errors.New(rsp.Description)
 }
 
-// RestartApp restarts app with given appName
+// RestartApp restarts app with given appName. Saving the settings unchanged
+// makes CapRover restart the app.
 func (c *Caprover) RestartApp(appName string) error {
-	err := c.updateAppDetails(UpdateAppRequest{
-		AppName: appName,
+	return c.PatchApp(appName, func(*UpdateAppRequest) error {
+		return nil
 	})
-
-	return err
 }
 
+// UpdateContainerHTTPPort sets the port the container of an app receives HTTP
+// traffic on.
 func (c *Caprover) UpdateContainerHTTPPort(appName string, newPort int) error {
-	err := c.updateAppDetails(UpdateAppRequest{
-		AppName:           appName,
-		ContainerHTTPPort: newPort,
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.ContainerHTTPPort = newPort
+		return nil
 	})
-
-	return err
 }
 
 func (c *Caprover) EnableWebsocketSupport(appName string) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	currentConfig.WebsocketSupport = true
-
-	if err != nil {
-		return err
-	}
-
-	err = c.updateAppDetails(currentConfig)
-
-	return err
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.WebsocketSupport = true
+		return nil
+	})
 }
 
 func (c *Caprover) EnableForceHTTPS(appName string) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	currentConfig.ForceSsl = true
-
-	if err != nil {
-		return err
-	}
-
-	err = c.updateAppDetails(currentConfig)
-
-	return err
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.ForceSsl = true
+		return nil
+	})
 }
 
 func (c *Caprover) DisableWebsocketSupport(appName string) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	currentConfig.WebsocketSupport = false
-
-	if err != nil {
-		return err
-	}
-
-	err = c.updateAppDetails(currentConfig)
-
-	return err
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.WebsocketSupport = false
+		return nil
+	})
 }
 
 func (c *Caprover) DisableForceHTTPS(appName string) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	currentConfig.ForceSsl = false
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.ForceSsl = false
+		return nil
+	})
+}
 
-	if err != nil {
-		return err
-	}
+// UpdateInstanceCount (appName string, instances int) error: This method sets
+// the number of instances of an application, 0 stopping it. If the update is
+// successful, it returns nil; otherwise, it returns an error.
+func (c *Caprover) UpdateInstanceCount(appName string, instances int) error {
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.InstanceCount = instances
+		return nil
+	})
+}
 
-	err = c.updateAppDetails(currentConfig)
+// SetHTTPAuth (appName string, user string, password string) error: This method
+// protects an application with HTTP basic auth, or changes its user or
+// password. The other settings of the app are kept. If the update is
+// successful, it returns nil; otherwise, it returns an error.
+func (c *Caprover) SetHTTPAuth(appName string, user string, password string) error {
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.HTTPAuth = &HTTPAuth{User: user, Password: password}
+		return nil
+	})
+}
 
-	return err
+// ClearHTTPAuth (appName string) error: This method removes the HTTP basic auth
+// of an application. The other settings of the app are kept. If the update is
+// successful, it returns nil; otherwise, it returns an error.
+func (c *Caprover) ClearHTTPAuth(appName string) error {
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.HTTPAuth = nil
+		return nil
+	})
 }
 
 func (c *Caprover) TurnInstanceCountZero(appName string) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	currentConfig.InstanceCount = 0
-
-	if err != nil {
-		return err
-	}
-
-	err = c.updateAppDetails(currentConfig)
-
-	return err
+	return c.UpdateInstanceCount(appName, 0)
 }
 
 func (c *Caprover) TurnInstanceCountOne(appName string) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	currentConfig.InstanceCount = 1
-
-	if err != nil {
-		return err
-	}
-
-	err = c.updateAppDetails(currentConfig)
-
-	return err
+	return c.UpdateInstanceCount(appName, 1)
 }
 
 func (c *Caprover) UpdateGitRepoInfo(appName string, repoInfo AppRepoInfo) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	currentConfig.AppPushWebhook.RepoInfo = repoInfo
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		req.AppPushWebhook.RepoInfo = repoInfo
+		return nil
+	})
+}
 
-	if err != nil {
-		return err
-	}
+// UpdateResourceConstraint sets the memory and CPU limits of an app, 0
+// removing a limit. The other settings of its ServiceUpdateOverride are kept.
+func (c *Caprover) UpdateResourceConstraint(appName string, memoryInMB int64, cpuInUnits float64) error {
+	memory := memoryInMB * ResourceOneMb
+	cpus := NanoCPUs(cpuInUnits)
 
-	err = c.updateAppDetails(currentConfig)
+	return c.UpdateResourceLimits(appName, ResourceLimits{MemoryBytes: &memory, NanoCPUs: &cpus})
+}
 
-	return err
+// ResourceLimits changes the limits of an app. A nil limit is left as it is,
+// a limit of 0 is removed.
+type ResourceLimits struct {
+	MemoryBytes *int64
+	NanoCPUs    *int64
 }
 
-func (c *Caprover) UpdateResourceConstraint(appName string, memoryInMB int64, cpuInUnits float64) error {
-	currentConfig, err := c.GetDefaultUpdateRequest(appName)
-
-	suo, err := json.Marshal(ServiceUpdateOverride{
-		TaskTemplate: SUOTaskTemplate{
-			Resources: SUOResources{
-				Limits: SUOLimits{
-					MemoryBytes: memoryInMB * ResourceOneMb,
-					NanoCPUs:    int64(cpuInUnits * float64(ResourceOneCpu)),
-				},
-			},
-		},
+// UpdateResourceLimits changes the limits of an app in its
+// ServiceUpdateOverride, keeping everything else the override sets.
+func (c *Caprover) UpdateResourceLimits(appName string, limits ResourceLimits) error {
+	return c.PatchApp(appName, func(req *UpdateAppRequest) error {
+		suo, err := EditServiceUpdateOverride(req.ServiceUpdateOverride, func(root map[string]any) {
+			if limits.MemoryBytes != nil {
+				SetOverrideValue(root, LimitValue(*limits.MemoryBytes), "TaskTemplate", "Resources", "Limits", "MemoryBytes")
+			}
+			if limits.NanoCPUs != nil {
+				SetOverrideValue(root, LimitValue(*limits.NanoCPUs), "TaskTemplate", "Resources", "Limits", "NanoCPUs")
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		req.ServiceUpdateOverride = suo
+		return nil
 	})
+}
 
-	currentConfig.ServiceUpdateOverride = string(suo)
+// NanoCPUs converts a number of CPU cores to the NanoCPUs of a limit.
+func NanoCPUs(cores float64) int64 {
+	return int64(cores * float64(ResourceOneCpu))
+}
 
-	if err != nil {
-		return err
+// LimitValue returns the value a limit is set to in a ServiceUpdateOverride:
+// nil for a limit of 0, which removes it.
+func LimitValue(v int64) *int64 {
+	if v == 0 {
+		return nil
 	}
-
-	err = c.updateAppDetails(currentConfig)
-
-	return err
+	return &v
 }
 
 // GetBuildLogs retrieves the build logs for a specific application
 func (c *Caprover) GetBuildLogs(appName string) (string, error) {
-	fmt.Println("Getting Build Logs")
+	fmt.Fprintln(os.Stderr, "Getting Build Logs")
 
 	url := c.buildURL(URLAppBuildLog) + "/" + appName + "/"
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
-	}
-	
-	c.addHeaders(req)
-
-	res, err := c.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
-
 	// Use a limited reader to prevent excessive memory usage
 	const maxLogSize = 10 * 1024 * 1024 // 10MB limit
-	body, err := io.ReadAll(io.LimitReader(res.Body, maxLogSize))
+	body, err := c.doRequest("GET", url, nil, maxLogSize)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %w", err)
+		return "", err
 	}
 
 	var rsp AppBuildLogResponse
@@ -698,33 +714,16 @@ func (c *Caprover) GetBuildLogs(appName string) (string, error) {
 	return logLines, nil
 }
 
-// GetAppLogs retrieves the application logs for a specific application
+// GetAppLogs retrieves the application logs for a specific application.
+// It prints nothing, so its result can be piped.
 func (c *Caprover) GetAppLogs(appName string) (string, error) {
-	fmt.Println("Getting App Logs")
-
 	url := c.buildURL(URLAppBuildLog) + "/" + appName + "/logs"
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
-	}
-	
-	c.addHeaders(req)
-
-	res, err := c.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
-
 	// Use a limited reader to prevent excessive memory usage
 	const maxLogSize = 10 * 1024 * 1024 // 10MB limit
-	body, err := io.ReadAll(io.LimitReader(res.Body, maxLogSize))
+	body, err := c.doRequest("GET", url, nil, maxLogSize)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %w", err)
+		return "", err
 	}
 
 	var rsp AppLogResponse
@@ -740,37 +739,72 @@ func (c *Caprover) GetAppLogs(appName string) (string, error) {
 // `appName` parameter. If the deletion is successful, it returns nil; otherwise,
 // it returns an error.
 func (c *Caprover) RemoveApp(appName string) error {
-	fmt.Println("Attempting to Remove an APP")
+	return c.RemoveAppWithVolumes(appName, nil)
+}
+
+// RemoveAppWithVolumes (appName string, volumes []string) error: This method
+// deletes an application together with the given persistent volumes. It sends
+// a POST request to the Caprover app delete endpoint with the provided appName
+// and volumes parameters. Volumes shared with other applications should be
+// left out. If the deletion is successful, it returns nil; otherwise, it
+// returns an error.
+func (c *Caprover) RemoveAppWithVolumes(appName string, volumes []string) error {
+	fmt.Fprintln(os.Stderr, "Attempting to Remove an APP")
 
 	url := c.buildURL(URLAppDeletePath)
 
-	data := make(map[string]string)
-	data["appName"] = appName
+	defer c.apps.invalidate(appName)
+
+	data := map[string]any{
+		"appName": appName,
+		"volumes": append([]string{}, volumes...),
+	}
 	jsonEncode, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	payload := bytes.NewBuffer(jsonEncode)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, payload)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return err
+	}
+
+	var rsp GenericAppResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
+	}
+
+	if rsp.Status == 100 {
+		return nil
 	}
 
-	c.addHeaders(req)
+	return errors.New(rsp.Description)
+}
 
-	res, err := c.client.Do(req)
+// RenameApp (oldAppName string, newAppName string) error: This method renames
+// an application. It sends a POST request to the Caprover app rename endpoint
+// with the provided oldAppName and newAppName parameters. Caprover keeps the
+// settings and versions of the application, but its internal hostname
+// srv-captain--<name> changes with the name. If the rename is successful, it
+// returns nil; otherwise, it returns an error.
+func (c *Caprover) RenameApp(oldAppName string, newAppName string) error {
+	fmt.Fprintln(os.Stderr, "Attempting to rename an APP")
+
+	url := c.buildURL(URLAppRenamePath)
+
+	defer c.apps.invalidate(oldAppName, newAppName)
+
+	jsonEncode, err := json.Marshal(map[string]string{
+		"oldAppName": oldAppName,
+		"newAppName": newAppName,
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("error marshaling request data: %w", err)
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
+		return err
 	}
 
 	var rsp GenericAppResponse
@@ -778,7 +812,7 @@ func (c *Caprover) RemoveApp(appName string) error {
 		return fmt.Errorf("error unmarshaling response: %w", err)
 	}
 
-	if rsp.Status == 100 {
+	if rsp.Status == StatusOK {
 		return nil
 	}
 
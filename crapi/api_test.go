@@ -0,0 +1,165 @@
+package crapi_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/crapi/crapitest"
+)
+
+// newTestServer starts a fake CapRover holding an app for each name, whose
+// logins are slowed down to widen the window for concurrent re-logins.
+func newTestServer(t *testing.T, appNames ...string) *crapitest.Server {
+	t.Helper()
+
+	srv := crapitest.NewServer("")
+	for _, name := range appNames {
+		srv.AddApp(crapi.AppDefinition{AppName: name, InstanceCount: 1})
+	}
+	srv.Inject(crapitest.Fault{Path: crapi.URLLoginPath, Latency: 20 * time.Millisecond})
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newTestClient(t *testing.T, srv *crapitest.Server) *crapi.Caprover {
+	t.Helper()
+
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatalf("NewCaproverInstance: %v", err)
+	}
+
+	return &c
+}
+
+// parallel runs fn from n goroutines at the same time.
+func parallel(n int, fn func(i int)) {
+	var start, done sync.WaitGroup
+	start.Add(1)
+	for i := 0; i < n; i++ {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			start.Wait()
+			fn(i)
+		}(i)
+	}
+	start.Done()
+	done.Wait()
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	srv := newTestServer(t)
+
+	if _, err := crapi.NewCaproverInstance(srv.URL, "wrong"); err == nil {
+		t.Fatal("expected an error for a wrong password")
+	}
+}
+
+func TestConcurrentReadsShareOneListing(t *testing.T) {
+	srv := newTestServer(t, "app-a", "app-b", "app-c")
+	c := newTestClient(t, srv)
+
+	parallel(50, func(i int) {
+		name := []string{"app-a", "app-b", "app-c"}[i%3]
+		if _, err := c.GetAppDetailFor(name); err != nil {
+			t.Errorf("GetAppDetailFor(%s): %v", name, err)
+		}
+		if _, err := c.GetDefaultUpdateRequest(name); err != nil {
+			t.Errorf("GetDefaultUpdateRequest(%s): %v", name, err)
+		}
+	})
+
+	if got := srv.Requests(crapi.URLAppListPath); got != 1 {
+		t.Errorf("app list fetched %d times, want 1", got)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	names := []string{"app-a", "app-b", "app-c", "app-d"}
+	srv := newTestServer(t, names...)
+	c := newTestClient(t, srv)
+
+	parallel(40, func(i int) {
+		name := names[i%len(names)]
+		req, err := c.GetDefaultUpdateRequest(name)
+		if err != nil {
+			t.Errorf("GetDefaultUpdateRequest(%s): %v", name, err)
+			return
+		}
+
+		req.InstanceCount = 3
+		if err := c.UpdateConfig(req); err != nil {
+			t.Errorf("UpdateConfig(%s): %v", name, err)
+		}
+		_ = c.Token()
+	})
+
+	if got := srv.Requests(crapi.URLUpdateAppPath); got != 40 {
+		t.Errorf("got %d updates, want 40", got)
+	}
+
+	for _, name := range names {
+		app, err := c.GetAppDetailFor(name)
+		if err != nil {
+			t.Fatalf("GetAppDetailFor(%s): %v", name, err)
+		}
+		if app.InstanceCount != 3 {
+			t.Errorf("%s has %d instances after update, want 3", name, app.InstanceCount)
+		}
+	}
+}
+
+func TestExpiredTokenReloginIsSingleFlight(t *testing.T) {
+	srv := newTestServer(t, "app-a")
+	c := newTestClient(t, srv)
+	before := c.Token()
+
+	srv.ExpireTokens()
+
+	parallel(50, func(i int) {
+		if _, err := c.GetBuildLogs("app-a"); err != nil {
+			t.Errorf("GetBuildLogs: %v", err)
+		}
+	})
+
+	if got := srv.Logins(); got != 2 {
+		t.Errorf("got %d logins, want 2 (initial + one re-login)", got)
+	}
+	if c.Token() == before {
+		t.Error("token was not renewed")
+	}
+}
+
+func TestConcurrentLoginCallsShareRequest(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv)
+
+	parallel(20, func(i int) {
+		if err := c.Login(); err != nil {
+			t.Errorf("Login: %v", err)
+		}
+	})
+
+	if got := srv.Logins(); got > 3 {
+		t.Errorf("got %d logins, concurrent calls were not shared", got)
+	}
+}
+
+func TestSetToken(t *testing.T) {
+	srv := newTestServer(t, "app-a")
+	c := newTestClient(t, srv)
+	other := newTestClient(t, srv)
+
+	// a token obtained elsewhere is used as is, without logging in again
+	c.SetToken(other.Token())
+	if _, err := c.GetAppDetailFor("app-a"); err != nil {
+		t.Fatalf("GetAppDetailFor: %v", err)
+	}
+	if c.Token() != other.Token() || srv.Logins() != 2 {
+		t.Errorf("the token set wasn't used: %d logins", srv.Logins())
+	}
+}
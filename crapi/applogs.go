@@ -0,0 +1,75 @@
+package crapi
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LogLine is a single line of the runtime logs of an app.
+type LogLine struct {
+	// Time is when Docker received the line, zero when it has no timestamp.
+	Time time.Time
+	// Text is the line without its timestamp.
+	Text string
+	// Raw is the line as CapRover returned it, timestamp included.
+	Raw string
+}
+
+// SplitAppLogs splits the raw payload returned by GetAppLogs into lines,
+// stripping the Docker stream framing and parsing the timestamps CapRover
+// asks Docker to prefix lines with.
+func SplitAppLogs(raw string) []LogLine {
+	raw = StripDockerFraming(raw)
+
+	var lines []LogLine
+	for _, text := range strings.Split(raw, "\n") {
+		text = strings.TrimSuffix(text, "\r")
+		if text == "" {
+			continue
+		}
+
+		line := LogLine{Text: text, Raw: text}
+		if stamp, rest, ok := strings.Cut(text, " "); ok {
+			if t, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+				line.Time = t
+				line.Text = rest
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// StripDockerFraming removes the 8 byte headers Docker puts in front of every
+// frame of a multiplexed stdout/stderr stream: the stream type (0, 1 or 2),
+// three zero bytes and the big endian frame size. The payload went through
+// JSON as a string, so size bytes that aren't valid UTF-8 were replaced with
+// U+FFFD; the size is therefore skipped as four runes.
+func StripDockerFraming(raw string) string {
+	if !strings.Contains(raw, "\x00\x00\x00") {
+		return raw
+	}
+
+	var b strings.Builder
+	b.Grow(len(raw))
+
+	for i := 0; i < len(raw); {
+		if raw[i] <= 2 && strings.HasPrefix(raw[i+1:], "\x00\x00\x00") {
+			j := i + 4
+			for n := 0; n < 4 && j < len(raw); n++ {
+				_, size := utf8.DecodeRuneInString(raw[j:])
+				j += size
+			}
+			i = j
+			continue
+		}
+
+		b.WriteByte(raw[i])
+		i++
+	}
+
+	return b.String()
+}
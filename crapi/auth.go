@@ -0,0 +1,212 @@
+package crapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// authState guards the auth token of a Caprover client. At most one login is
+// in flight at a time; concurrent callers wait for it and share its result.
+type authState struct {
+	mu    sync.Mutex
+	token string
+	login *loginCall
+}
+
+// loginCall is a login in progress. done is closed once err is set.
+type loginCall struct {
+	done chan struct{}
+	err  error
+}
+
+func newAuthState() *authState {
+	return &authState{}
+}
+
+func (a *authState) get() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.token
+}
+
+func (a *authState) set(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = token
+}
+
+// do runs login, or waits for the login already in flight.
+func (a *authState) do(login func() error) error {
+	a.mu.Lock()
+	if call := a.login; call != nil {
+		a.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+
+	call := &loginCall{done: make(chan struct{})}
+	a.login = call
+	a.mu.Unlock()
+
+	call.err = login()
+
+	a.mu.Lock()
+	a.login = nil
+	a.mu.Unlock()
+	close(call.done)
+
+	return call.err
+}
+
+// Token returns the current auth token. It replaces the Token field of
+// earlier versions, which couldn't be read safely while requests were made.
+func (c *Caprover) Token() string {
+	return c.auth.get()
+}
+
+// SetToken replaces the auth token, e.g. with one saved from an earlier
+// session. An expired token is renewed by logging in again.
+func (c *Caprover) SetToken(token string) {
+	c.auth.set(token)
+}
+
+// reauthenticate logs in again after staleToken was rejected. When another
+// goroutine already replaced staleToken, its token is reused instead.
+func (c *Caprover) reauthenticate(staleToken string) error {
+	if c.auth.get() != staleToken {
+		return nil
+	}
+
+	return c.Login()
+}
+
+// apiRequest describes a call to the CapRover API.
+type apiRequest struct {
+	method string
+	url    string
+	// body returns the request body and its content type. It is called again
+	// when the request is retried, nil means no body.
+	body func() (io.Reader, string, error)
+	// maxSize limits how much of the response is read, zero or negative means
+	// no limit.
+	maxSize int64
+	// timeout bounds the whole request, zero means 30 seconds.
+	timeout time.Duration
+}
+
+// doRequest sends a JSON request with the auth headers and returns the
+// response body. The body is read up to maxSize bytes; a negative maxSize
+// means no limit.
+func (c *Caprover) doRequest(method string, url string, payload []byte, maxSize int64) ([]byte, error) {
+	r := apiRequest{method: method, url: url, maxSize: maxSize}
+	if payload != nil {
+		r.body = func() (io.Reader, string, error) {
+			return bytes.NewReader(payload), "", nil
+		}
+	}
+
+	return c.do(r)
+}
+
+// do sends a request with the auth headers and returns the response body.
+// When CapRover rejects the token, the client logs in again and the request
+// is retried once.
+func (c *Caprover) do(r apiRequest) ([]byte, error) {
+	token := c.Token()
+
+	body, expired, err := c.send(r, token)
+	if err != nil || !expired {
+		return body, err
+	}
+
+	if c.appToken != "" {
+		return nil, errors.New("CapRover rejected the app token, it may have been rotated or disabled")
+	}
+
+	if err := c.reauthenticate(token); err != nil {
+		return nil, fmt.Errorf("error renewing auth token: %w", err)
+	}
+
+	body, _, err = c.send(r, c.Token())
+	return body, err
+}
+
+// send performs a single request and reports whether the token was rejected.
+func (c *Caprover) send(r apiRequest, token string) ([]byte, bool, error) {
+	timeout := r.timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var reqBody io.Reader
+	var contentType string
+	if r.body != nil {
+		var err error
+		reqBody, contentType, err = r.body()
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, reqBody)
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating request: %w", err)
+	}
+
+	c.addHeaders(req, token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	client := c.client
+	if r.timeout > client.Timeout {
+		// the client's own timeout would cut long uploads short
+		longer := *c.client
+		longer.Timeout = r.timeout
+		client = &longer
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+
+	var reader io.Reader = res.Body
+	if r.maxSize > 0 {
+		reader = io.LimitReader(res.Body, r.maxSize)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return body, true, nil
+	}
+
+	if res.StatusCode >= 400 {
+		return nil, false, fmt.Errorf("unexpected response from %s: %s", req.URL.Path, res.Status)
+	}
+
+	var status struct {
+		Status int `json:"status"`
+	}
+	if json.Unmarshal(body, &status) == nil && status.Status == StatusAuthTokenInvalid {
+		return body, true, nil
+	}
+
+	return body, false, nil
+}
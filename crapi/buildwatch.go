@@ -0,0 +1,92 @@
+package crapi
+
+import (
+	"fmt"
+	"time"
+)
+
+// BuildWatchOptions configures WatchBuild.
+type BuildWatchOptions struct {
+	// Interval between two polls of the build status.
+	Interval time.Duration
+	// Timeout bounds the whole watch.
+	Timeout time.Duration
+	// Seen holds log lines that were already shown, e.g. taken with
+	// GetBuildStatus before a deploy, so that they aren't emitted again.
+	Seen []string
+	// OnLine, when set, is called with every new build log line in order.
+	OnLine func(line string)
+}
+
+// WatchBuild polls the build status and log of an app until the build is
+// over, emitting only the log lines it has not seen before through
+// opts.OnLine, and returns the final status.
+//
+// A build that was only just queued may not be reported as running yet, so
+// an idle app is only taken as done once it was seen building or after a few
+// polls. It returns a *BuildFailedError when the build failed.
+func (c *Caprover) WatchBuild(appName string, opts BuildWatchOptions) (AppBuildLogData, error) {
+	const idlePolls = 3
+
+	deadline := time.Now().Add(opts.Timeout)
+	seen := opts.Seen
+	seenBuilding := false
+
+	for polls := 1; ; polls++ {
+		status, err := c.GetBuildStatus(appName)
+		if err != nil {
+			return AppBuildLogData{}, err
+		}
+
+		if opts.OnLine != nil {
+			for _, line := range NewLogLines(seen, status.Logs.Lines) {
+				opts.OnLine(line)
+			}
+		}
+		seen = status.Logs.Lines
+
+		if status.IsAppBuilding {
+			seenBuilding = true
+		} else if seenBuilding || polls >= idlePolls {
+			if status.IsBuildFailed {
+				return status, newBuildFailedError(appName, status.Logs.Lines)
+			}
+			return status, nil
+		}
+
+		if time.Now().After(deadline) {
+			return status, fmt.Errorf("timed out after %s waiting for the build of %s", opts.Timeout, appName)
+		}
+
+		time.Sleep(opts.Interval)
+	}
+}
+
+// NewLogLines returns the lines of cur that come after prev. CapRover only
+// keeps the most recent log lines, so the start of prev may have been
+// dropped from cur: the longest end of prev that cur starts with is taken as
+// the overlap. Without any overlap all of cur is new.
+func NewLogLines(prev []string, cur []string) []string {
+	for start := 0; start < len(prev); start++ {
+		overlap := prev[start:]
+		if len(overlap) > len(cur) {
+			continue
+		}
+
+		if equalLines(overlap, cur[:len(overlap)]) {
+			return cur[len(overlap):]
+		}
+	}
+
+	return cur
+}
+
+func equalLines(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,29 @@
+package crapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewLogLines(t *testing.T) {
+	tests := []struct {
+		name string
+		prev []string
+		cur  []string
+		want []string
+	}{
+		{"first poll", nil, []string{"a", "b"}, []string{"a", "b"}},
+		{"appended", []string{"a", "b"}, []string{"a", "b", "c"}, []string{"c"}},
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, []string{}},
+		{"oldest lines dropped", []string{"a", "b", "c"}, []string{"b", "c", "d", "e"}, []string{"d", "e"}},
+		{"log restarted", []string{"a", "b"}, []string{"x", "y"}, []string{"x", "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewLogLines(tt.prev, tt.cur); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewLogLines(%v, %v) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package crapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultAppDetailsTTL is how long a client reuses the app list it fetched,
+// see SetAppDetailsTTL.
+const DefaultAppDetailsTTL = 5 * time.Second
+
+// appsCache keeps the last app list fetched from CapRover so that lookups of
+// single apps don't download every app definition again.
+//
+// The snapshot is stored as the raw response body and decoded on every read,
+// so callers never share slices with each other or with the cache. Writes mark
+// the apps they touch as stale; reading a stale app, or the full list while any
+// app is stale, fetches a new snapshot. A bulk operation over N apps therefore
+// costs one list fetch plus one per app that is read again after being changed.
+// The snapshot expires after ttl, so changes made outside this client show up
+// in long-lived clients too.
+//
+// It is safe for concurrent use. Concurrent misses wait for a single fetch,
+// made without holding the lock, so reads the snapshot can serve don't wait
+// for it.
+type appsCache struct {
+	mu        sync.Mutex
+	body      []byte
+	fetchedAt time.Time
+	stale     map[string]bool
+	all       bool
+	// gen counts the invalidations, a fetch started before one can't clear it
+	gen      int
+	inflight *appsFetch
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+// appsFetch is a fetch of the app list in progress.
+type appsFetch struct {
+	gen  int
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+func newAppsCache() *appsCache {
+	return &appsCache{stale: map[string]bool{}, all: true, ttl: DefaultAppDetailsTTL, now: time.Now}
+}
+
+// fresh reports whether the snapshot can serve a read of appName, or of the
+// full list when appName is empty. ac.mu must be held.
+func (ac *appsCache) fresh(appName string) bool {
+	if ac.all || ac.body == nil || ac.now().Sub(ac.fetchedAt) >= ac.ttl {
+		return false
+	}
+	if appName == "" {
+		return len(ac.stale) == 0
+	}
+	return !ac.stale[appName]
+}
+
+// get returns the snapshot, calling fetch first when the snapshot is missing,
+// expired or, with appName set, when that app is stale. An empty appName asks
+// for the full list, which is refreshed when any app is stale.
+func (ac *appsCache) get(appName string, fetch func() ([]byte, error)) (ListAppResponse, error) {
+	if ac == nil {
+		body, err := fetch()
+		if err != nil {
+			return ListAppResponse{}, err
+		}
+
+		rsp, err := decodeAppList(body)
+		if err == nil && rsp.Status != StatusOK {
+			return ListAppResponse{}, fmt.Errorf("error listing apps: %s", rsp.Description)
+		}
+		return rsp, err
+	}
+
+	ac.mu.Lock()
+	for {
+		if ac.fresh(appName) {
+			body := ac.body
+			ac.mu.Unlock()
+			return decodeAppList(body)
+		}
+
+		call := ac.inflight
+		if call == nil {
+			break
+		}
+
+		// share the fetch in progress, unless it started before the last
+		// invalidation: then wait for it and check again
+		joined := call.gen == ac.gen
+		ac.mu.Unlock()
+		<-call.done
+		if joined {
+			if call.err != nil {
+				return ListAppResponse{}, call.err
+			}
+			return decodeAppList(call.body)
+		}
+		ac.mu.Lock()
+	}
+
+	call := &appsFetch{gen: ac.gen, done: make(chan struct{})}
+	ac.inflight = call
+	ac.mu.Unlock()
+
+	rsp, body, err := fetchAppList(fetch)
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.inflight = nil
+	call.body, call.err = body, err
+	close(call.done)
+
+	switch {
+	case err != nil:
+		// don't keep error responses around, e.g. an expired token
+		ac.body = nil
+		ac.all = true
+	case call.gen == ac.gen:
+		ac.body = body
+		ac.fetchedAt = ac.now()
+		ac.all = false
+		ac.stale = map[string]bool{}
+	default:
+		// invalidated while fetching: keep the stale marks for the next read
+		ac.body = body
+		ac.fetchedAt = ac.now()
+	}
+	if err != nil {
+		return ListAppResponse{}, err
+	}
+
+	return rsp, nil
+}
+
+// fetchAppList fetches and decodes the app list, turning error responses into
+// errors.
+func fetchAppList(fetch func() ([]byte, error)) (ListAppResponse, []byte, error) {
+	body, err := fetch()
+	if err != nil {
+		return ListAppResponse{}, nil, err
+	}
+
+	rsp, err := decodeAppList(body)
+	if err != nil {
+		return ListAppResponse{}, nil, err
+	}
+
+	if rsp.Status != StatusOK {
+		return ListAppResponse{}, nil, fmt.Errorf("error listing apps: %s", rsp.Description)
+	}
+
+	return rsp, body, nil
+}
+
+// invalidate marks the given apps as stale. Without any app names the whole
+// snapshot is dropped.
+func (ac *appsCache) invalidate(appNames ...string) {
+	if ac == nil {
+		return
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.gen++
+	if len(appNames) == 0 {
+		ac.all = true
+		return
+	}
+
+	for _, name := range appNames {
+		ac.stale[name] = true
+	}
+}
+
+func decodeAppList(body []byte) (ListAppResponse, error) {
+	var rsp ListAppResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return ListAppResponse{}, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+
+	return rsp, nil
+}
+
+// InvalidateAppDetails drops the cached app list, or only the given apps when
+// app names are passed, so the next read fetches fresh data from CapRover.
+// Every write made through this client already invalidates what it changed;
+// this is for changes made elsewhere, e.g. from the dashboard.
+func (c *Caprover) InvalidateAppDetails(appNames ...string) {
+	c.apps.invalidate(appNames...)
+}
+
+// SetAppDetailsTTL sets how long the app list fetched by this client is
+// reused, DefaultAppDetailsTTL by default. Reads within the ttl don't see
+// changes made outside this client unless InvalidateAppDetails is called;
+// 0 fetches the list for every read that isn't shared with a concurrent one.
+func (c *Caprover) SetAppDetailsTTL(ttl time.Duration) {
+	if c.apps == nil {
+		return
+	}
+
+	c.apps.mu.Lock()
+	defer c.apps.mu.Unlock()
+
+	c.apps.ttl = ttl
+}
@@ -0,0 +1,183 @@
+package crapi
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeList serves an app list to an appsCache and counts the fetches.
+type fakeList struct {
+	fetches atomic.Int32
+	// gate, when set, blocks fetches until it is closed
+	gate    chan struct{}
+	started chan struct{}
+	err     error
+}
+
+func (f *fakeList) fetch() ([]byte, error) {
+	n := f.fetches.Add(1)
+	if f.started != nil {
+		f.started <- struct{}{}
+	}
+	if f.gate != nil {
+		<-f.gate
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var rsp ListAppResponse
+	rsp.Status = StatusOK
+	rsp.Data.AppDefinitions = []AppDefinition{{AppName: "a", InstanceCount: int(n)}, {AppName: "b"}}
+	return json.Marshal(rsp)
+}
+
+func TestAppsCacheInvalidation(t *testing.T) {
+	ac := newAppsCache()
+	f := &fakeList{}
+
+	get := func(appName string, fetches int32) {
+		t.Helper()
+		if _, err := ac.get(appName, f.fetch); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.fetches.Load(); got != fetches {
+			t.Fatalf("after reading %q: %d fetches, want %d", appName, got, fetches)
+		}
+	}
+
+	get("a", 1)
+	get("b", 1)
+	get("", 1)
+
+	ac.invalidate("a")
+	get("b", 1) // b didn't change
+	get("a", 2)
+
+	ac.invalidate("b")
+	get("", 3) // the full list includes b
+
+	ac.invalidate()
+	get("b", 4)
+}
+
+func TestAppsCacheExpires(t *testing.T) {
+	now := time.Now()
+	ac := newAppsCache()
+	ac.now = func() time.Time { return now }
+	f := &fakeList{}
+
+	ac.get("", f.fetch)
+	now = now.Add(DefaultAppDetailsTTL / 2)
+	ac.get("", f.fetch)
+	if got := f.fetches.Load(); got != 1 {
+		t.Errorf("%d fetches within the ttl, want 1", got)
+	}
+
+	now = now.Add(DefaultAppDetailsTTL)
+	ac.get("", f.fetch)
+	if got := f.fetches.Load(); got != 2 {
+		t.Errorf("%d fetches after the ttl, want 2", got)
+	}
+}
+
+func TestAppsCacheErrorsAreNotKept(t *testing.T) {
+	ac := newAppsCache()
+	f := &fakeList{err: errors.New("boom")}
+
+	if _, err := ac.get("a", f.fetch); err == nil {
+		t.Fatal("expected the fetch error")
+	}
+
+	f.err = nil
+	if _, err := ac.get("a", f.fetch); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.fetches.Load(); got != 2 {
+		t.Errorf("%d fetches, want 2", got)
+	}
+}
+
+func TestAppsCacheConcurrentMissesShareOneFetch(t *testing.T) {
+	ac := newAppsCache()
+	f := &fakeList{gate: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ac.get("a", f.fetch); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(f.gate)
+	wg.Wait()
+
+	if got := f.fetches.Load(); got != 1 {
+		t.Errorf("%d fetches, want 1", got)
+	}
+}
+
+func TestAppsCacheFreshReadsDontWaitForAFetch(t *testing.T) {
+	ac := newAppsCache()
+	f := &fakeList{}
+	ac.get("", f.fetch)
+
+	// a slow refresh of a
+	f.gate, f.started = make(chan struct{}), make(chan struct{})
+	ac.invalidate("a")
+	refreshed := make(chan ListAppResponse)
+	go func() {
+		rsp, _ := ac.get("a", f.fetch)
+		refreshed <- rsp
+	}()
+	<-f.started
+
+	done := make(chan error)
+	go func() {
+		_, err := ac.get("b", f.fetch)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reading a fresh app waited for the refresh of another one")
+	}
+
+	close(f.gate)
+	if rsp := <-refreshed; rsp.Data.AppDefinitions[0].InstanceCount != 2 {
+		t.Errorf("a wasn't refreshed: %+v", rsp.Data.AppDefinitions[0])
+	}
+}
+
+func TestAppsCacheInvalidationDuringFetch(t *testing.T) {
+	ac := newAppsCache()
+	gate := make(chan struct{})
+	f := &fakeList{gate: gate, started: make(chan struct{}, 1)}
+
+	go ac.get("a", f.fetch)
+	<-f.started
+
+	// a write lands while the list is fetched, the fetched list may predate it
+	ac.invalidate("a")
+	close(gate)
+
+	rsp, err := ac.get("a", f.fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.fetches.Load(); got != 2 || rsp.Data.AppDefinitions[0].InstanceCount != 2 {
+		t.Errorf("a wasn't fetched again after the write: %d fetches, %+v", got, rsp.Data.AppDefinitions[0])
+	}
+}
@@ -28,6 +28,28 @@ const (
 	URLEnableBaseDomainSslPath   = "/api/v2/user/apps/appDefinitions/enablebasedomainssl"
 	URLAddCustomDomainPath       = "/api/v2/user/apps/appDefinitions/customdomain"
 	URLEnableCustomDomainSslPath = "/api/v2/user/apps/appDefinitions/enablecustomdomainssl"
+	URLRemoveCustomDomainPath    = "/api/v2/user/apps/appDefinitions/removecustomdomain"
 	URLAppBuildLog               = "/api/v2/user/apps/appData"
 	URLAppDeletePath             = "/api/v2/user/apps/appDefinitions/delete"
+	URLAppRenamePath             = "/api/v2/user/apps/appDefinitions/rename"
+	URLSystemNodesPath           = "/api/v2/user/system/nodes"
+	URLProjectsPath              = "/api/v2/user/projects/"
+	URLProjectRegisterPath       = "/api/v2/user/projects/register"
+	URLProjectUpdatePath         = "/api/v2/user/projects/update"
+	URLProjectDeletePath         = "/api/v2/user/projects/delete"
+)
+
+// Status codes returned by CapRover in the "status" field of every response.
+const (
+	StatusOK               = 100
+	StatusOKDeployStarted  = 101
+	StatusErrorGeneric     = 1000
+	StatusNotAuthorized    = 1102
+	StatusAlreadyExist     = 1103
+	StatusBadName          = 1104
+	StatusWrongPassword    = 1105
+	StatusAuthTokenInvalid = 1106
+	StatusBuildError       = 1109
+	StatusIllegalParameter = 1110
+	StatusNotFound         = 1111
 )
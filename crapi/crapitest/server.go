@@ -0,0 +1,1145 @@
+// Package crapitest provides an in-process fake CapRover server for testing
+// code built on the crapi package, in the spirit of net/http/httptest.
+//
+// The fake keeps its apps in memory, hands out auth tokens on login and
+// answers with CapRover's status codes: 100 when a request succeeds, an error
+// status with a description otherwise. Faults such as latency, 5xx responses
+// and expired tokens can be injected per endpoint.
+package crapitest
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+)
+
+// DefaultPassword is the password accepted by a server created with an empty
+// password.
+const DefaultPassword = "captain42"
+
+// Fault changes how the server answers requests.
+type Fault struct {
+	// Path limits the fault to requests whose path starts with it. An empty
+	// Path matches every request.
+	Path string
+	// Times is the number of requests the fault applies to. Zero means until
+	// ClearFaults is called.
+	Times int
+	// Latency delays the response.
+	Latency time.Duration
+	// HTTPStatus, when set, answers with this HTTP status and a plain text body.
+	HTTPStatus int
+	// Status and Description, when Status is set, answer with a CapRover error.
+	Status      int
+	Description string
+	// ExpireToken rejects the request as if its auth token had expired.
+	ExpireToken bool
+}
+
+// Server is a fake CapRover API listening on a local loopback address.
+type Server struct {
+	*httptest.Server
+
+	// Password is the password accepted by the login endpoint.
+	Password string
+	// RootDomain is reported in the app list.
+	RootDomain string
+	// DefaultNginxConfig is the NGINX template of apps without a custom one,
+	// reported in the app list.
+	DefaultNginxConfig string
+	// Nodes are the nodes of the cluster.
+	Nodes []crapi.Node
+	// BuildPolls is the number of build status or app list requests for which
+	// a new build is reported as running. With zero, builds finish immediately.
+	BuildPolls int
+
+	mu       sync.Mutex
+	apps     map[string]*app
+	tokens   map[string]bool
+	logins   int
+	faults   []*Fault
+	requests map[string]int
+
+	deletedVolumes []string
+	projects       []crapi.ProjectDefinition
+	projectSeq     int
+}
+
+// app is the in-memory state of a single app.
+type app struct {
+	def         crapi.AppDefinition
+	buildLogs   []string
+	appLogs     string
+	build       *build
+	buildFailed bool
+	failBuilds  bool
+	uploads     [][]string
+	archives    [][]byte
+	// deployTokens counts the deploy tokens generated so far
+	deployTokens int
+	// extra holds the definition fields crapi doesn't model
+	extra map[string]json.RawMessage
+}
+
+// build is a build in progress.
+type build struct {
+	image   string
+	gitHash string
+	polls   int
+}
+
+// NewServer starts a fake CapRover that accepts password and already holds
+// the given apps. The caller should call Close when finished.
+func NewServer(password string, apps ...crapi.AppDefinition) *Server {
+	if password == "" {
+		password = DefaultPassword
+	}
+
+	s := &Server{
+		Password:   password,
+		RootDomain: "example.com",
+		apps:       map[string]*app{},
+		tokens:     map[string]bool{},
+		requests:   map[string]int{},
+	}
+
+	for _, def := range apps {
+		s.AddApp(def)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(crapi.URLLoginPath, s.handleLogin)
+	mux.HandleFunc(crapi.URLAppListPath, s.authorized(s.handleList))
+	mux.HandleFunc(crapi.URLAppRegisterPath, s.authorized(s.handleRegister))
+	mux.HandleFunc(crapi.URLUpdateAppPath, s.authorized(s.handleUpdate))
+	mux.HandleFunc(crapi.URLAppDeletePath, s.authorized(s.handleDelete))
+	mux.HandleFunc(crapi.URLAppRenamePath, s.authorized(s.handleRename))
+	mux.HandleFunc(crapi.URLAppTriggerBuild, s.handleTriggerBuild)
+	mux.HandleFunc(crapi.URLEnableBaseDomainSslPath, s.authorized(s.handleBaseDomainSsl))
+	mux.HandleFunc(crapi.URLAddCustomDomainPath, s.authorized(s.handleAddCustomDomain))
+	mux.HandleFunc(crapi.URLEnableCustomDomainSslPath, s.authorized(s.handleCustomDomainSsl))
+	mux.HandleFunc(crapi.URLRemoveCustomDomainPath, s.authorized(s.handleRemoveCustomDomain))
+	mux.HandleFunc(crapi.URLSystemNodesPath, s.authorized(s.handleNodes))
+	mux.HandleFunc(crapi.URLProjectsPath, s.authorized(s.handleProjects))
+	mux.HandleFunc(crapi.URLProjectRegisterPath, s.authorized(s.handleProjectRegister))
+	mux.HandleFunc(crapi.URLProjectUpdatePath, s.authorized(s.handleProjectUpdate))
+	mux.HandleFunc(crapi.URLProjectDeletePath, s.authorized(s.handleProjectDelete))
+	mux.HandleFunc(crapi.URLAppBuildLog+"/", s.authorizedForApp(s.handleAppData))
+
+	s.Server = httptest.NewServer(s.withFaults(mux))
+
+	return s
+}
+
+// HashPassword returns the hash the server stores for an HTTP basic auth
+// password.
+func HashPassword(password string) string {
+	return "hashed:" + password
+}
+
+// AddApp adds or replaces an app. Zero instance counts and HTTP ports are set
+// to CapRover's defaults.
+func (s *Server) AddApp(def crapi.AppDefinition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if def.ContainerHTTPPort == 0 {
+		def.ContainerHTTPPort = 80
+	}
+	if def.AppPushWebhook.PushWebhookToken == "" {
+		def.AppPushWebhook.PushWebhookToken = "webhook-" + def.AppName
+	}
+
+	s.apps[def.AppName] = &app{def: copyDefinition(def)}
+}
+
+// App returns the current definition of an app.
+func (s *Server) App(name string) (crapi.AppDefinition, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[name]
+	if !ok {
+		return crapi.AppDefinition{}, false
+	}
+
+	return copyDefinition(a.def), true
+}
+
+// Apps returns the definitions of all apps sorted by name.
+func (s *Server) Apps() []crapi.AppDefinition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defs := s.appList()
+	for i := range defs {
+		defs[i] = copyDefinition(defs[i])
+	}
+
+	return defs
+}
+
+// copyDefinition returns a copy of def that shares no slices or pointers with
+// it, so callers can't change the state of the server.
+func copyDefinition(def crapi.AppDefinition) crapi.AppDefinition {
+	def.Networks = slices.Clone(def.Networks)
+	def.EnvVars = slices.Clone(def.EnvVars)
+	def.Volumes = slices.Clone(def.Volumes)
+	def.Ports = slices.Clone(def.Ports)
+	def.Versions = slices.Clone(def.Versions)
+	def.CustomDomain = slices.Clone(def.CustomDomain)
+	def.Tags = slices.Clone(def.Tags)
+	def.Raw = slices.Clone(def.Raw)
+	if def.HTTPAuth != nil {
+		auth := *def.HTTPAuth
+		def.HTTPAuth = &auth
+	}
+
+	return def
+}
+
+// SetBuildLogs replaces the build log lines of an app.
+func (s *Server) SetBuildLogs(name string, lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.apps[name]; ok {
+		a.buildLogs = lines
+	}
+}
+
+// FailBuilds makes the next builds of an app fail, or succeed again.
+func (s *Server) FailBuilds(name string, fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.apps[name]; ok {
+		a.failBuilds = fail
+	}
+}
+
+// Uploads returns the file names of every source archive uploaded for an
+// app, oldest first.
+func (s *Server) Uploads(name string) [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.apps[name]; ok {
+		return append([][]string(nil), a.uploads...)
+	}
+
+	return nil
+}
+
+// Archives returns the content of every source archive uploaded for an app,
+// oldest first.
+func (s *Server) Archives(name string) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.apps[name]; ok {
+		return append([][]byte(nil), a.archives...)
+	}
+
+	return nil
+}
+
+// SetAppLogs replaces the raw runtime logs of an app.
+func (s *Server) SetAppLogs(name string, logs string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.apps[name]; ok {
+		a.appLogs = logs
+	}
+}
+
+// Inject adds a fault. Faults are checked in the order they were added and
+// the first matching one is applied.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// ExpireTokens invalidates every token handed out so far, as CapRover does
+// when its auth secret changes.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = map[string]bool{}
+}
+
+// Logins returns the number of successful logins.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+// Requests returns the number of requests received for a path, faults
+// included.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+func (s *Server) withFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		var fault Fault
+		for i, f := range s.faults {
+			if !strings.HasPrefix(r.URL.Path, f.Path) {
+				continue
+			}
+			fault = *f
+			if f.Times > 0 {
+				f.Times--
+				if f.Times == 0 {
+					s.faults = append(s.faults[:i], s.faults[i+1:]...)
+				}
+			}
+			break
+		}
+		s.mu.Unlock()
+
+		if fault.Latency > 0 {
+			time.Sleep(fault.Latency)
+		}
+
+		switch {
+		case fault.HTTPStatus != 0:
+			http.Error(w, http.StatusText(fault.HTTPStatus), fault.HTTPStatus)
+		case fault.Status != 0:
+			reply(w, fault.Status, fault.Description, nil)
+		case fault.ExpireToken:
+			reply(w, crapi.StatusAuthTokenInvalid, "Auth token corrupted", nil)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		ok := s.tokens[r.Header.Get("x-captain-auth")]
+		s.mu.Unlock()
+
+		if !ok {
+			reply(w, crapi.StatusAuthTokenInvalid, "Auth token corrupted", nil)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// authorizedForApp also accepts the deploy token of the app named in the path,
+// like CapRover does for the app data endpoints.
+func (s *Server) authorizedForApp(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("x-captain-app-token")
+		if token == "" {
+			s.authorized(next)(w, r)
+			return
+		}
+
+		rest := strings.TrimPrefix(r.URL.Path, crapi.URLAppBuildLog+"/")
+		name, _, _ := strings.Cut(rest, "/")
+
+		s.mu.Lock()
+		a, ok := s.apps[name]
+		ok = ok && a.def.AppDeployTokenConfig.Enabled && a.def.AppDeployTokenConfig.AppDeployToken == token
+		s.mu.Unlock()
+
+		if !ok {
+			reply(w, crapi.StatusAuthTokenInvalid, "Invalid app token", nil)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func reply(w http.ResponseWriter, status int, description string, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":      status,
+		"description": description,
+		"data":        data,
+	})
+}
+
+// decode reads a JSON request body, answering with an error when it is invalid.
+// The body is decoded into every value of vs.
+func decode(w http.ResponseWriter, r *http.Request, vs ...any) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		for _, v := range vs {
+			if err = json.Unmarshal(body, v); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		reply(w, crapi.StatusIllegalParameter, "invalid request body: "+err.Error(), nil)
+		return false
+	}
+
+	return true
+}
+
+// definitionFields are the JSON names of the fields crapi.AppDefinition
+// models. Other fields of an update are kept as extra fields.
+var definitionFields = func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(crapi.AppDefinition{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+// listedDefinition is an app definition as listed, extra fields included.
+// s.mu must be held.
+func (a *app) listedDefinition() any {
+	if len(a.extra) == 0 {
+		return a.def
+	}
+
+	data, _ := json.Marshal(a.def)
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+	for key, value := range a.extra {
+		fields[key] = value
+	}
+
+	return fields
+}
+
+// SetField sets a field of an app definition that crapi doesn't model, to
+// check that updates keep it. value is encoded as JSON.
+func (s *Server) SetField(name string, key string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.apps[name]; ok {
+		if a.extra == nil {
+			a.extra = map[string]json.RawMessage{}
+		}
+		a.extra[key] = data
+	}
+}
+
+// Field returns the JSON of a field set with SetField, or by an update.
+func (s *Server) Field(name string, key string) (json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[name]
+	if !ok {
+		return nil, false
+	}
+
+	value, ok := a.extra[key]
+	return value, ok
+}
+
+// appList returns the app definitions sorted by name. s.mu must be held.
+func (s *Server) appList() []crapi.AppDefinition {
+	defs := make([]crapi.AppDefinition, 0, len(s.apps))
+	for _, a := range s.apps {
+		defs = append(defs, a.def)
+	}
+
+	sort.Slice(defs, func(i, j int) bool { return defs[i].AppName < defs[j].AppName })
+
+	return defs
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.Password != s.Password {
+		reply(w, crapi.StatusWrongPassword, "Password is incorrect.", nil)
+		return
+	}
+
+	s.mu.Lock()
+	s.logins++
+	token := fmt.Sprintf("token-%d", s.logins)
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	reply(w, crapi.StatusOK, "Login succeeded", map[string]string{"token": token})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	for _, a := range s.apps {
+		s.tick(a)
+	}
+	defs := make([]any, 0, len(s.apps))
+	for _, def := range s.appList() {
+		defs = append(defs, s.apps[def.AppName].listedDefinition())
+	}
+	s.mu.Unlock()
+
+	reply(w, crapi.StatusOK, "App definitions are retrieved.", map[string]any{
+		"appDefinitions":     defs,
+		"rootDomain":         s.RootDomain,
+		"defaultNginxConfig": s.DefaultNginxConfig,
+	})
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppName           string `json:"appName"`
+		HasPersistentData bool   `json:"hasPersistentData"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.AppName == "" {
+		reply(w, crapi.StatusBadName, "App name is required", nil)
+		return
+	}
+
+	if _, ok := s.App(req.AppName); ok {
+		reply(w, crapi.StatusAlreadyExist, "App already exists: "+req.AppName, nil)
+		return
+	}
+
+	s.AddApp(crapi.AppDefinition{
+		AppName:           req.AppName,
+		HasPersistentData: req.HasPersistentData,
+		InstanceCount:     1,
+	})
+
+	reply(w, crapi.StatusOK, "App Definition Saved", nil)
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	var req crapi.UpdateAppRequest
+	var fields map[string]json.RawMessage
+	if !decode(w, r, &req, &fields) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[req.AppName]
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+req.AppName, nil)
+		return
+	}
+
+	if req.ProjectID != "" && s.project(req.ProjectID) == nil {
+		reply(w, crapi.StatusNotFound, "Project not found: "+req.ProjectID, nil)
+		return
+	}
+	if req.RedirectDomain != "" && !s.hasDomain(a, req.RedirectDomain) {
+		reply(w, crapi.StatusIllegalParameter, "Redirect domain must be one of the app's domains: "+req.RedirectDomain, nil)
+		return
+	}
+
+	// like CapRover, settings missing from the update are reset
+	a.extra = nil
+	for key, value := range fields {
+		if !definitionFields[key] {
+			if a.extra == nil {
+				a.extra = map[string]json.RawMessage{}
+			}
+			a.extra[key] = value
+		}
+	}
+
+	d := &a.def
+	d.RedirectDomain = req.RedirectDomain
+	d.InstanceCount = req.InstanceCount
+	d.CaptainDefinitionRelativeFilePath = req.CaptainDefinitionRelativeFilePath
+	d.NotExposeAsWebApp = req.NotExposeAsWebApp
+	d.ForceSsl = req.ForceSsl
+	d.WebsocketSupport = req.WebsocketSupport
+	d.Volumes = req.Volumes
+	d.Ports = req.Ports
+	d.AppPushWebhook.RepoInfo = req.AppPushWebhook.RepoInfo
+	d.NodeID = req.NodeID
+	d.ProjectID = req.ProjectID
+	d.Tags = req.Tags
+	// like CapRover, the networks of an app can't be updated
+	d.PreDeployFunction = req.PreDeployFunction
+	d.ServiceUpdateOverride = req.ServiceUpdateOverride
+	d.CustomNginxConfig = req.CustomNginxConfig
+	d.ContainerHTTPPort = req.ContainerHTTPPort
+	d.Description = req.Description
+	d.EnvVars = req.EnvVars
+	d.AppDeployTokenConfig = req.AppDeployTokenConfig
+	switch {
+	case !d.AppDeployTokenConfig.Enabled:
+		d.AppDeployTokenConfig.AppDeployToken = ""
+	case d.AppDeployTokenConfig.AppDeployToken == "":
+		a.deployTokens++
+		d.AppDeployTokenConfig.AppDeployToken = fmt.Sprintf("deploy-token-%s-%d", d.AppName, a.deployTokens)
+	}
+	d.HTTPAuth = nil
+	if auth := req.HTTPAuth; auth != nil {
+		// like CapRover, a new password is hashed and the hash is kept otherwise
+		if auth.Password != "" {
+			auth.PasswordHashed = HashPassword(auth.Password)
+			auth.Password = ""
+		}
+		d.HTTPAuth = auth
+	}
+
+	reply(w, crapi.StatusOK, "Updated App Definition Saved", nil)
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reply(w, crapi.StatusOK, "Node info retrieved", map[string]any{
+		"nodes": append([]crapi.Node{}, s.Nodes...),
+	})
+}
+
+// AddProject adds a project and returns its ID.
+func (s *Server) AddProject(name string, parentProjectID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addProject(crapi.ProjectDefinition{Name: name, ParentProjectID: parentProjectID})
+}
+
+// Projects returns the projects in the order they were created.
+func (s *Server) Projects() []crapi.ProjectDefinition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]crapi.ProjectDefinition(nil), s.projects...)
+}
+
+func (s *Server) addProject(p crapi.ProjectDefinition) string {
+	s.projectSeq++
+	p.ID = fmt.Sprintf("project-%d", s.projectSeq)
+	s.projects = append(s.projects, p)
+	return p.ID
+}
+
+// project returns the project with the given ID, or nil.
+func (s *Server) project(id string) *crapi.ProjectDefinition {
+	for i := range s.projects {
+		if s.projects[i].ID == id {
+			return &s.projects[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reply(w, crapi.StatusOK, "Projects are retrieved.", map[string]any{
+		"projects": append([]crapi.ProjectDefinition{}, s.projects...),
+	})
+}
+
+func (s *Server) handleProjectRegister(w http.ResponseWriter, r *http.Request) {
+	var req crapi.ProjectDefinition
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Name == "" {
+		reply(w, crapi.StatusIllegalParameter, "Project name is empty", nil)
+		return
+	}
+	if req.ParentProjectID != "" && s.project(req.ParentProjectID) == nil {
+		reply(w, crapi.StatusNotFound, "Parent project not found: "+req.ParentProjectID, nil)
+		return
+	}
+
+	s.addProject(crapi.ProjectDefinition{Name: req.Name, ParentProjectID: req.ParentProjectID, Description: req.Description})
+	reply(w, crapi.StatusOK, "Project created", nil)
+}
+
+func (s *Server) handleProjectUpdate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ProjectDefinition crapi.ProjectDefinition `json:"projectDefinition"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.project(req.ProjectDefinition.ID)
+	if p == nil {
+		reply(w, crapi.StatusNotFound, "Project not found: "+req.ProjectDefinition.ID, nil)
+		return
+	}
+
+	*p = req.ProjectDefinition
+	reply(w, crapi.StatusOK, "Project updated", nil)
+}
+
+func (s *Server) handleProjectDelete(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ProjectIDs []string `json:"projectIds"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range req.ProjectIDs {
+		if s.project(id) == nil {
+			reply(w, crapi.StatusNotFound, "Project not found: "+id, nil)
+			return
+		}
+		for _, a := range s.apps {
+			if a.def.ProjectID == id {
+				reply(w, crapi.StatusIllegalParameter, "Project still has apps: "+id, nil)
+				return
+			}
+		}
+		for _, p := range s.projects {
+			if p.ParentProjectID == id && !slices.Contains(req.ProjectIDs, p.ID) {
+				reply(w, crapi.StatusIllegalParameter, "Project still has sub-projects: "+id, nil)
+				return
+			}
+		}
+	}
+
+	s.projects = slices.DeleteFunc(s.projects, func(p crapi.ProjectDefinition) bool {
+		return slices.Contains(req.ProjectIDs, p.ID)
+	})
+	reply(w, crapi.StatusOK, "Projects deleted", nil)
+}
+
+// hasDomain reports whether domain is the base domain or a custom domain of
+// an app.
+func (s *Server) hasDomain(a *app, domain string) bool {
+	if domain == a.def.AppName+"."+s.RootDomain {
+		return true
+	}
+	for _, d := range a.def.CustomDomain {
+		if d.PublicDomain == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppName string   `json:"appName"`
+		Volumes []string `json:"volumes"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	_, ok := s.apps[req.AppName]
+	delete(s.apps, req.AppName)
+	if ok {
+		s.deletedVolumes = append(s.deletedVolumes, req.Volumes...)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+req.AppName, nil)
+		return
+	}
+
+	reply(w, crapi.StatusOK, "App is deleted", nil)
+}
+
+func (s *Server) handleRename(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OldAppName string `json:"oldAppName"`
+		NewAppName string `json:"newAppName"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[req.OldAppName]
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+req.OldAppName, nil)
+		return
+	}
+	if req.NewAppName == "" {
+		reply(w, crapi.StatusBadName, "App name is required", nil)
+		return
+	}
+	if _, exists := s.apps[req.NewAppName]; exists {
+		reply(w, crapi.StatusAlreadyExist, "App already exists: "+req.NewAppName, nil)
+		return
+	}
+
+	delete(s.apps, req.OldAppName)
+	a.def.AppName = req.NewAppName
+	s.apps[req.NewAppName] = a
+
+	reply(w, crapi.StatusOK, "AppName is changed.", nil)
+}
+
+// DeletedVolumes returns the volumes deleted together with apps.
+func (s *Server) DeletedVolumes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.deletedVolumes...)
+}
+
+// handleTriggerBuild authenticates with the webhook token in the query, like
+// CapRover does, and records a new deployed version.
+func (s *Server) handleTriggerBuild(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.apps {
+		if token == "" || a.def.AppPushWebhook.PushWebhookToken != token {
+			continue
+		}
+
+		s.startBuild(a, builtImage(a), "")
+		reply(w, crapi.StatusOK, "Build webhook has triggered", nil)
+		return
+	}
+
+	reply(w, crapi.StatusNotAuthorized, "Invalid webhook token", nil)
+}
+
+// startBuild starts building a new version of an app, which finishes after
+// BuildPolls status requests. s.mu must be held.
+func (s *Server) startBuild(a *app, image string, gitHash string) {
+	a.buildLogs = append(a.buildLogs, "Build started for "+a.def.AppName)
+	a.build = &build{image: image, gitHash: gitHash, polls: s.BuildPolls}
+	a.def.IsAppBuilding = true
+
+	if s.BuildPolls == 0 {
+		s.finishBuild(a)
+	}
+}
+
+// tick advances the build in progress of an app by one poll. s.mu must be
+// held.
+func (s *Server) tick(a *app) {
+	if a.build == nil {
+		return
+	}
+
+	a.build.polls--
+	if a.build.polls < 0 {
+		s.finishBuild(a)
+	}
+}
+
+// finishBuild ends the build in progress of an app. s.mu must be held.
+func (s *Server) finishBuild(a *app) {
+	b := a.build
+	a.build = nil
+	a.def.IsAppBuilding = false
+	a.buildFailed = a.failBuilds
+
+	if a.failBuilds {
+		a.buildLogs = append(a.buildLogs, "Build has failed!")
+		return
+	}
+
+	s.deploy(a, b.image, b.gitHash)
+}
+
+// builtImage names the image CapRover builds for the next version of an app.
+func builtImage(a *app) string {
+	return fmt.Sprintf("img-captain--%s:%d", a.def.AppName, len(a.def.Versions))
+}
+
+// deploy records a new version of an app as deployed. s.mu must be held.
+func (s *Server) deploy(a *app, image string, gitHash string) {
+	version := len(a.def.Versions)
+	a.def.Versions = append(a.def.Versions, crapi.AppVersion{
+		Version:           version,
+		TimeStamp:         time.Now().UTC(),
+		DeployedImageName: image,
+		GitHash:           gitHash,
+	})
+	a.def.DeployedVersion = version
+	a.buildLogs = append(a.buildLogs, fmt.Sprintf("Deployed version %d", version))
+}
+
+func (s *Server) handleBaseDomainSsl(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppName string `json:"appName"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[req.AppName]
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+req.AppName, nil)
+		return
+	}
+
+	a.def.HasDefaultSubDomainSsl = true
+	reply(w, crapi.StatusOK, "App is now available over HTTPS", nil)
+}
+
+func (s *Server) handleAddCustomDomain(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppName      string `json:"appName"`
+		CustomDomain string `json:"customDomain"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[req.AppName]
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+req.AppName, nil)
+		return
+	}
+
+	for _, d := range a.def.CustomDomain {
+		if d.PublicDomain == req.CustomDomain {
+			reply(w, crapi.StatusAlreadyExist, "Domain is already in use: "+req.CustomDomain, nil)
+			return
+		}
+	}
+
+	a.def.CustomDomain = append(a.def.CustomDomain, crapi.CustomDomain{PublicDomain: req.CustomDomain})
+	reply(w, crapi.StatusOK, "Domain is added", nil)
+}
+
+func (s *Server) handleRemoveCustomDomain(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppName      string `json:"appName"`
+		CustomDomain string `json:"customDomain"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[req.AppName]
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+req.AppName, nil)
+		return
+	}
+
+	for i, d := range a.def.CustomDomain {
+		if d.PublicDomain == req.CustomDomain {
+			a.def.CustomDomain = append(a.def.CustomDomain[:i:i], a.def.CustomDomain[i+1:]...)
+			reply(w, crapi.StatusOK, "Custom domain is removed for: "+req.AppName, nil)
+			return
+		}
+	}
+
+	reply(w, crapi.StatusNotFound, "Domain not found: "+req.CustomDomain, nil)
+}
+
+func (s *Server) handleCustomDomainSsl(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppName      string `json:"appName"`
+		CustomDomain string `json:"customDomain"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[req.AppName]
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+req.AppName, nil)
+		return
+	}
+
+	for i, d := range a.def.CustomDomain {
+		if d.PublicDomain == req.CustomDomain {
+			a.def.CustomDomain[i].HasSsl = true
+			reply(w, crapi.StatusOK, "Custom domain is now enabled for SSL", nil)
+			return
+		}
+	}
+
+	reply(w, crapi.StatusNotFound, "Domain not found: "+req.CustomDomain, nil)
+}
+
+// handleAppData serves the build status at appData/<app>/ and the runtime
+// logs at appData/<app>/logs.
+func (s *Server) handleAppData(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, crapi.URLAppBuildLog+"/")
+	name, sub, _ := strings.Cut(rest, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[name]
+	if !ok {
+		reply(w, crapi.StatusNotFound, "App not found: "+name, nil)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && sub == "":
+		s.tick(a)
+		reply(w, crapi.StatusOK, "App build status retrieved", map[string]any{
+			"isAppBuilding": a.def.IsAppBuilding,
+			"isBuildFailed": a.buildFailed,
+			"logs":          map[string]any{"lines": a.buildLogs},
+		})
+	case r.Method == http.MethodPost && sub == "":
+		s.handleDeploy(w, r, a)
+	case r.Method == http.MethodGet && sub == "logs":
+		reply(w, crapi.StatusOK, "App runtime logs are retrieved", map[string]any{
+			"logs": a.appLogs,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleDeploy starts a build from either an uploaded source archive or the
+// content of a captain-definition file. s.mu must be held.
+func (s *Server) handleDeploy(w http.ResponseWriter, r *http.Request, a *app) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		s.handleDeployDefinition(w, r, a)
+		return
+	}
+
+	file, _, err := r.FormFile("sourceFile")
+	if err != nil {
+		reply(w, crapi.StatusIllegalParameter, "sourceFile is required: "+err.Error(), nil)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		reply(w, crapi.StatusIllegalParameter, "error reading sourceFile: "+err.Error(), nil)
+		return
+	}
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			reply(w, crapi.StatusIllegalParameter, "invalid source archive: "+err.Error(), nil)
+			return
+		}
+		names = append(names, header.Name)
+	}
+
+	a.uploads = append(a.uploads, names)
+	a.archives = append(a.archives, data)
+	s.startBuild(a, builtImage(a), "")
+
+	reply(w, crapi.StatusOKDeployStarted, "Deploy is started", nil)
+}
+
+// handleDeployDefinition deploys from a captainDefinitionContent. Only
+// definitions with an imageName are supported. s.mu must be held.
+func (s *Server) handleDeployDefinition(w http.ResponseWriter, r *http.Request, a *app) {
+	var req struct {
+		CaptainDefinitionContent string `json:"captainDefinitionContent"`
+		GitHash                  string `json:"gitHash"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	var definition crapi.CaptainDefinition
+	if err := json.Unmarshal([]byte(req.CaptainDefinitionContent), &definition); err != nil {
+		reply(w, crapi.StatusIllegalParameter, "invalid captain definition: "+err.Error(), nil)
+		return
+	}
+
+	if definition.ImageName == "" {
+		reply(w, crapi.StatusIllegalParameter, "crapitest only deploys captain definitions with an imageName", nil)
+		return
+	}
+
+	s.startBuild(a, definition.ImageName, req.GitHash)
+
+	reply(w, crapi.StatusOKDeployStarted, "Deploy is started", nil)
+}
@@ -0,0 +1,32 @@
+package crapitest
+
+import (
+	"testing"
+
+	"github.com/pararang/letgofur/crapi"
+)
+
+func TestAppsAreCopies(t *testing.T) {
+	srv := NewServer("", crapi.AppDefinition{
+		AppName:      "api",
+		CustomDomain: []crapi.CustomDomain{{PublicDomain: "api.com"}},
+		Versions:     []crapi.AppVersion{{Version: 0, DeployedImageName: "api:1"}},
+		Tags:         []crapi.AppTag{{TagName: "team=web"}},
+		HTTPAuth:     &crapi.HTTPAuth{User: "admin"},
+	})
+	defer srv.Close()
+
+	app, _ := srv.App("api")
+	app.CustomDomain[0].HasSsl = true
+	app.Versions[0].DeployedImageName = "changed"
+	app.Tags[0].TagName = "changed"
+	app.HTTPAuth.User = "changed"
+
+	apps := srv.Apps()
+	apps[0].Tags[0].TagName = "changed"
+
+	app, _ = srv.App("api")
+	if app.CustomDomain[0].HasSsl || app.Versions[0].DeployedImageName != "api:1" || app.Tags[0].TagName != "team=web" || app.HTTPAuth.User != "admin" {
+		t.Errorf("the server's app was changed through a returned copy: %+v", app)
+	}
+}
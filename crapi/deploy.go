@@ -0,0 +1,316 @@
+package crapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"time"
+)
+
+// ErrBuildFailed is returned when CapRover reports a failed build.
+var ErrBuildFailed = errors.New("build failed")
+
+// buildLogTail is the number of build log lines kept in a BuildFailedError.
+const buildLogTail = 20
+
+// BuildFailedError is returned when the build of an app fails. It matches
+// ErrBuildFailed with errors.Is and carries the end of the build log.
+type BuildFailedError struct {
+	AppName string
+	LogTail []string
+}
+
+func (e *BuildFailedError) Error() string {
+	return fmt.Sprintf("build of %s failed", e.AppName)
+}
+
+func (e *BuildFailedError) Unwrap() error {
+	return ErrBuildFailed
+}
+
+func newBuildFailedError(appName string, lines []string) *BuildFailedError {
+	if len(lines) > buildLogTail {
+		lines = lines[len(lines)-buildLogTail:]
+	}
+
+	return &BuildFailedError{AppName: appName, LogTail: lines}
+}
+
+// uploadTimeout bounds source uploads, which take longer than API calls.
+const uploadTimeout = 30 * time.Minute
+
+// UploadProgress is called while a source archive is uploaded with the number
+// of bytes sent so far and the total size.
+type UploadProgress func(sent int64, total int64)
+
+// DeploySourceArchive uploads a tar archive of an app's source code and starts
+// a build from it, like `caprover deploy` does. The archive must contain the
+// captain-definition file at the app's CaptainDefinitionRelativeFilePath.
+// It returns once the build is queued; use WaitForBuild to wait for it.
+func (c *Caprover) DeploySourceArchive(appName string, archive io.ReadSeeker, size int64, progress UploadProgress) error {
+	fmt.Fprintln(os.Stderr, "Attempting to Upload Source")
+
+	url := c.buildURL(URLAppBuildLog) + "/" + appName + "?detached=1"
+
+	defer c.apps.invalidate(appName)
+
+	upload := &archiveUpload{archive: archive, size: size, progress: progress}
+	defer upload.stop()
+
+	body, err := c.do(apiRequest{
+		method:  "POST",
+		url:     url,
+		timeout: uploadTimeout,
+		body:    upload.body,
+	})
+	if err != nil {
+		return err
+	}
+
+	var rsp GenericAppResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
+	}
+
+	if rsp.Status == StatusOK || rsp.Status == StatusOKDeployStarted {
+		return nil
+	}
+
+	return errors.New(rsp.Description)
+}
+
+// errUploadStopped ends the upload of an attempt that is retried.
+var errUploadStopped = errors.New("upload stopped")
+
+// archiveUpload streams a source archive as a multipart form, from the start
+// again on every attempt of the request.
+type archiveUpload struct {
+	archive  io.ReadSeeker
+	size     int64
+	progress UploadProgress
+
+	// pr and done belong to the attempt in progress
+	pr   *io.PipeReader
+	done chan struct{}
+}
+
+// body rewinds the archive and streams it for a new attempt. The writer of the
+// previous attempt may still be reading the archive, so it is stopped first.
+func (u *archiveUpload) body() (io.Reader, string, error) {
+	u.stop()
+
+	if _, err := u.archive.Seek(0, io.SeekStart); err != nil {
+		return nil, "", fmt.Errorf("error rewinding source archive: %w", err)
+	}
+
+	var contentType string
+	u.pr, contentType, u.done = multipartArchive(u.archive, u.size, u.progress)
+	return u.pr, contentType, nil
+}
+
+// stop ends the attempt in progress, if any, and waits until its writer no
+// longer reads the archive.
+func (u *archiveUpload) stop() {
+	if u.pr == nil {
+		return
+	}
+
+	u.pr.CloseWithError(errUploadStopped)
+	<-u.done
+	u.pr = nil
+}
+
+// multipartArchive streams archive as the sourceFile field of a multipart
+// form. done is closed once the archive is no longer read.
+func multipartArchive(archive io.Reader, size int64, progress UploadProgress) (pr *io.PipeReader, contentType string, done chan struct{}) {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	done = make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		part, err := form.CreateFormFile("sourceFile", "source.tar")
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		var src io.Reader = archive
+		if progress != nil {
+			src = &progressReader{r: archive, total: size, progress: progress}
+		}
+
+		if _, err := io.Copy(part, src); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		pw.CloseWithError(form.Close())
+	}()
+
+	return pr, form.FormDataContentType(), done
+}
+
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress UploadProgress
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.progress(p.sent, p.total)
+	}
+	return n, err
+}
+
+// GetBuildStatus retrieves whether an app is building, whether its last build
+// failed and the build log lines.
+func (c *Caprover) GetBuildStatus(appName string) (AppBuildLogData, error) {
+	url := c.buildURL(URLAppBuildLog) + "/" + appName + "/"
+
+	// Use a limited reader to prevent excessive memory usage
+	const maxLogSize = 10 * 1024 * 1024 // 10MB limit
+	body, err := c.doRequest("GET", url, nil, maxLogSize)
+	if err != nil {
+		return AppBuildLogData{}, err
+	}
+
+	var rsp AppBuildLogResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return AppBuildLogData{}, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+
+	if rsp.Status != StatusOK {
+		return AppBuildLogData{}, errors.New(rsp.Description)
+	}
+
+	return rsp.Data, nil
+}
+
+// WaitForBuild polls the build status of an app every interval until the
+// build is over and returns the final status. It is WatchBuild without
+// following the log.
+func (c *Caprover) WaitForBuild(appName string, interval time.Duration, timeout time.Duration) (AppBuildLogData, error) {
+	return c.WatchBuild(appName, BuildWatchOptions{Interval: interval, Timeout: timeout})
+}
+
+// CaptainDefinition is the content of a captain-definition file.
+type CaptainDefinition struct {
+	SchemaVersion  int    `json:"schemaVersion"`
+	ImageName      string `json:"imageName,omitempty"`
+	DockerfilePath string `json:"dockerfilePath,omitempty"`
+}
+
+// DeployImage deploys a prebuilt Docker image, pulled by CapRover from its
+// registry. It returns once the deploy is started; use WaitForVersion to wait
+// for the new version to become active.
+func (c *Caprover) DeployImage(appName string, imageName string) error {
+	definition, err := json.Marshal(CaptainDefinition{SchemaVersion: 2, ImageName: imageName})
+	if err != nil {
+		return fmt.Errorf("error marshaling captain definition: %w", err)
+	}
+
+	return c.deployDefinition(appName, string(definition), "")
+}
+
+// deployDefinition starts a deploy from the content of a captain-definition
+// file.
+func (c *Caprover) deployDefinition(appName string, captainDefinitionContent string, gitHash string) error {
+	fmt.Fprintln(os.Stderr, "Attempting to Deploy App")
+
+	url := c.buildURL(URLAppBuildLog) + "/" + appName + "?detached=1"
+
+	defer c.apps.invalidate(appName)
+
+	data := make(map[string]string)
+	data["captainDefinitionContent"] = captainDefinitionContent
+	data["gitHash"] = gitHash
+	jsonEncode, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshaling request data: %w", err)
+	}
+
+	body, err := c.doRequest("POST", url, jsonEncode, -1)
+	if err != nil {
+		return err
+	}
+
+	var rsp GenericAppResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
+	}
+
+	if rsp.Status == StatusOK || rsp.Status == StatusOKDeployStarted {
+		return nil
+	}
+
+	return errors.New(rsp.Description)
+}
+
+// WaitForVersion waits until an app has a version newer than afterVersion
+// and that version is the deployed one, polling every interval. It returns
+// the app as it is then, or a *BuildFailedError when the build of the new
+// version failed. Pass -1 as afterVersion for apps without any
+// version yet. As with WaitForBuild, a failed build is only reported once
+// the new build was seen running or after a few polls.
+func (c *Caprover) WaitForVersion(appName string, afterVersion int, interval time.Duration, timeout time.Duration) (AppDefinition, error) {
+	const idlePolls = 3
+
+	deadline := time.Now().Add(timeout)
+	seenBuilding := false
+
+	for polls := 1; ; polls++ {
+		c.apps.invalidate(appName)
+		app, err := c.GetAppDetailFor(appName)
+		if err != nil {
+			return AppDefinition{}, err
+		}
+
+		if latest, ok := LatestVersion(app); ok && latest.Version > afterVersion && app.DeployedVersion == latest.Version {
+			return app, nil
+		}
+
+		if app.IsAppBuilding {
+			seenBuilding = true
+		} else if seenBuilding || polls >= idlePolls {
+			// a failed flag seen earlier may belong to the previous build
+			status, err := c.GetBuildStatus(appName)
+			if err != nil {
+				return app, err
+			}
+			if status.IsBuildFailed {
+				return app, newBuildFailedError(appName, status.Logs.Lines)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return app, fmt.Errorf("timed out after %s waiting for a new version of %s", timeout, appName)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// LatestVersion returns the most recent version of an app.
+func LatestVersion(app AppDefinition) (AppVersion, bool) {
+	if len(app.Versions) == 0 {
+		return AppVersion{}, false
+	}
+
+	latest := app.Versions[0]
+	for _, v := range app.Versions[1:] {
+		if v.Version > latest.Version {
+			latest = v
+		}
+	}
+
+	return latest, true
+}
@@ -0,0 +1,54 @@
+package crapi_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/crapi/crapitest"
+)
+
+func TestDeploySourceArchiveAfterExpiredToken(t *testing.T) {
+	srv := crapitest.NewServer("", crapi.AppDefinition{AppName: "api"})
+	defer srv.Close()
+
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// large enough for the first attempt to still be streaming when the
+	// server rejects its token
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for i := range 8 {
+		content := bytes.Repeat([]byte(fmt.Sprintf("file %d ", i)), 128*1024)
+		if err := tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("src/%d.txt", i), Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.ExpireTokens()
+	if err := c.DeploySourceArchive("api", bytes.NewReader(archive.Bytes()), int64(archive.Len()), nil); err != nil {
+		t.Fatalf("DeploySourceArchive: %v", err)
+	}
+
+	archives := srv.Archives("api")
+	if len(archives) != 1 {
+		t.Fatalf("got %d uploads, want 1", len(archives))
+	}
+	if !bytes.Equal(archives[0], archive.Bytes()) {
+		t.Errorf("the uploaded archive is corrupted: %d bytes, want %d", len(archives[0]), archive.Len())
+	}
+	if srv.Logins() != 2 {
+		t.Errorf("got %d logins, want 2", srv.Logins())
+	}
+}
@@ -0,0 +1,134 @@
+package crapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// captainSubdomain is the subdomain CapRover serves its dashboard and API on.
+const captainSubdomain = "captain"
+
+// NormalizeEndpoint turns a user supplied host into the base URL of a CapRover
+// API, e.g. "x.com", "captain.x.com/" and "https://captain.x.com/#/apps" all
+// become "https://captain.x.com".
+//
+// The scheme defaults to https (http for IP addresses and localhost, which is
+// how a fresh CapRover is reached on port 3000) and must be http or https.
+// Paths, queries and fragments are dropped, default ports are removed and other
+// ports are kept. When given a root domain without a scheme, e.g. "x.com" or
+// "x.co.uk", the "captain." subdomain is added. Other hosts, and every URL
+// with an explicit scheme, are kept as given, so dashboards served on another
+// subdomain or behind a proxy can be reached.
+func NormalizeEndpoint(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("endpoint is empty")
+	}
+
+	full := raw
+	hasScheme := strings.Contains(raw, "://")
+	if !hasScheme {
+		scheme := "https"
+		if isLocalOrIP(hostOf(raw)) {
+			scheme = "http"
+		}
+		full = scheme + "://" + raw
+	}
+
+	u, err := url.Parse(full)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", raw, err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("invalid endpoint %q: scheme must be http or https", raw)
+	}
+
+	if u.User != nil {
+		return "", fmt.Errorf("invalid endpoint %q: credentials must not be part of the host", raw)
+	}
+
+	hostname := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if hostname == "" {
+		return "", fmt.Errorf("invalid endpoint %q: missing hostname", raw)
+	}
+
+	if !isLocalOrIP(hostname) {
+		if !strings.Contains(hostname, ".") {
+			return "", fmt.Errorf("invalid endpoint %q: hostname must be a domain or an IP address", raw)
+		}
+		if !hasScheme && isRootDomain(hostname) {
+			hostname = captainSubdomain + "." + hostname
+		}
+	}
+
+	port := u.Port()
+	if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		port = ""
+	}
+
+	host := hostname
+	if strings.Contains(hostname, ":") {
+		host = "[" + hostname + "]"
+	}
+	if port != "" {
+		host = net.JoinHostPort(hostname, port)
+	}
+
+	return scheme + "://" + host, nil
+}
+
+// Hostname returns the hostname of the normalized endpoint, without the port.
+func (c *Caprover) Hostname() string {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return ""
+	}
+
+	return u.Hostname()
+}
+
+// hostOf extracts the host part of a scheme-less endpoint.
+func hostOf(raw string) string {
+	if i := strings.IndexAny(raw, "/?#"); i >= 0 {
+		raw = raw[:i]
+	}
+
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		return host
+	}
+
+	return strings.Trim(raw, "[]")
+}
+
+// secondLevelDomains are the labels commonly registered under country code
+// top-level domains, e.g. the "co" of "x.co.uk".
+var secondLevelDomains = map[string]bool{
+	"ac": true, "co": true, "com": true, "edu": true, "gov": true, "net": true, "or": true, "org": true,
+}
+
+// isRootDomain reports whether hostname is a registered domain rather than a
+// subdomain of one: "x.com" and "x.co.uk" are, "cp.x.com" isn't.
+func isRootDomain(hostname string) bool {
+	labels := strings.Split(hostname, ".")
+	switch len(labels) {
+	case 2:
+		return true
+	case 3:
+		return len(labels[2]) == 2 && secondLevelDomains[labels[1]]
+	}
+	return false
+}
+
+func isLocalOrIP(hostname string) bool {
+	return hostname == "localhost" || net.ParseIP(hostname) != nil
+}
+
+// BaseURL returns the normalized endpoint the client talks to.
+func (c *Caprover) BaseURL() string {
+	return c.Endpoint
+}
@@ -0,0 +1,77 @@
+package crapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Node availabilities reported in Node.Status. Only active nodes get new
+// tasks.
+const (
+	NodeActive = "active"
+	NodePause  = "pause"
+	NodeDrain  = "drain"
+)
+
+// Node holds a node of the Docker swarm CapRover runs on.
+type Node struct {
+	NodeID              string `json:"nodeId"`
+	Type                string `json:"type"`
+	IsLeader            bool   `json:"isLeader"`
+	Hostname            string `json:"hostname"`
+	Architecture        string `json:"architecture"`
+	OperatingSystem     string `json:"operatingSystem"`
+	NanoCPU             int64  `json:"nanoCpu"`
+	MemoryBytes         int64  `json:"memoryBytes"`
+	DockerEngineVersion string `json:"dockerEngineVersion"`
+	IP                  string `json:"ip"`
+	// State is the state Docker sees the node in, e.g. ready or down.
+	State string `json:"state"`
+	// Status is the availability of the node, one of NodeActive, NodePause
+	// and NodeDrain.
+	Status string `json:"status"`
+}
+
+// NodesResponse holds the response of the cluster nodes endpoint.
+type NodesResponse struct {
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+	Data        struct {
+		Nodes []Node `json:"nodes"`
+	} `json:"data"`
+}
+
+// GetNodes returns the nodes of the cluster.
+func (c *Caprover) GetNodes() ([]Node, error) {
+	body, err := c.doRequest("GET", c.buildURL(URLSystemNodesPath), nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsp NodesResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	if rsp.Status != StatusOK {
+		return nil, errors.New(rsp.Description)
+	}
+
+	return rsp.Data.Nodes, nil
+}
+
+// FindNode returns the node with the given ID or hostname.
+func FindNode(nodes []Node, idOrHostname string) (Node, bool) {
+	for _, n := range nodes {
+		if n.NodeID == idOrHostname {
+			return n, true
+		}
+	}
+	for _, n := range nodes {
+		if n.Hostname == idOrHostname {
+			return n, true
+		}
+	}
+
+	return Node{}, false
+}
@@ -0,0 +1,67 @@
+package crapi
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EditServiceUpdateOverride parses a ServiceUpdateOverride, YAML or JSON, lets
+// edit change it and returns it as YAML, the format letgofur workspaces use.
+// The keys edit doesn't touch are kept. An override left empty is returned
+// as "".
+func EditServiceUpdateOverride(override string, edit func(root map[string]any)) (string, error) {
+	root := map[string]any{}
+	if strings.TrimSpace(override) != "" {
+		if err := yaml.Unmarshal([]byte(override), &root); err != nil {
+			return "", fmt.Errorf("error parsing ServiceUpdateOverride: %w", err)
+		}
+		if root == nil {
+			root = map[string]any{}
+		}
+	}
+
+	edit(root)
+	if len(root) == 0 {
+		return "", nil
+	}
+
+	out, err := yaml.Marshal(root)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling ServiceUpdateOverride: %w", err)
+	}
+
+	return string(out), nil
+}
+
+// SetOverrideValue sets the value at path in a parsed ServiceUpdateOverride,
+// e.g. "TaskTemplate", "Resources", "Limits", "MemoryBytes", adding the
+// missing maps. A nil value removes the key, along with the maps of path it
+// leaves empty.
+func SetOverrideValue(root map[string]any, value *int64, path ...string) {
+	parents := []map[string]any{root}
+	m := root
+	for _, key := range path[:len(path)-1] {
+		child, ok := m[key].(map[string]any)
+		if !ok {
+			if value == nil {
+				return
+			}
+			child = map[string]any{}
+			m[key] = child
+		}
+		parents = append(parents, child)
+		m = child
+	}
+
+	if value != nil {
+		m[path[len(path)-1]] = *value
+		return
+	}
+
+	delete(m, path[len(path)-1])
+	for i := len(parents) - 1; i > 0 && len(parents[i]) == 0; i-- {
+		delete(parents[i-1], path[i-1])
+	}
+}
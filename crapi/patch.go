@@ -0,0 +1,57 @@
+package crapi
+
+import "sync"
+
+// PatchFunc changes some settings of an app in place. Returning an error
+// cancels the update.
+type PatchFunc func(req *UpdateAppRequest) error
+
+// PatchApp updates some settings of an app and keeps all the others. CapRover
+// only offers a full update that resets every setting missing from the
+// request, so the current settings are read first, patched, then sent back
+// whole. Patches of the same app made through this client are serialized, so
+// concurrent patches don't undo each other.
+func (c *Caprover) PatchApp(appName string, patch PatchFunc) error {
+	unlock := c.locks.lock(appName)
+	defer unlock()
+
+	current, err := c.GetDefaultUpdateRequest(appName)
+	if err != nil {
+		return err
+	}
+
+	if err := patch(&current); err != nil {
+		return err
+	}
+
+	return c.updateAppDetails(current)
+}
+
+// appLocks holds a mutex per app name.
+type appLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newAppLocks() *appLocks {
+	return &appLocks{locks: map[string]*sync.Mutex{}}
+}
+
+// lock locks appName and returns the function unlocking it. A nil appLocks
+// doesn't lock anything.
+func (l *appLocks) lock(appName string) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	m, ok := l.locks[appName]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[appName] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
@@ -0,0 +1,172 @@
+package crapi_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/crapi/crapitest"
+)
+
+func TestSingleFieldMutatorsKeepOtherSettings(t *testing.T) {
+	srv := crapitest.NewServer("", crapi.AppDefinition{
+		AppName:               "api",
+		InstanceCount:         2,
+		ContainerHTTPPort:     8080,
+		Volumes:               []crapi.VolumeInformation{{ContainerPath: "/data", VolumeName: "api-data"}},
+		Ports:                 []crapi.PortInformation{{ContainerPort: 22, HostPort: 2222}},
+		EnvVars:               []crapi.EnvVarInformation{{Key: "MODE", Value: "prod"}},
+		ServiceUpdateOverride: "TaskTemplate: {}\n",
+		HTTPAuth:              &crapi.HTTPAuth{User: "admin", PasswordHashed: crapitest.HashPassword("s3cret")},
+	})
+	defer srv.Close()
+
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.RestartApp("api"); err != nil {
+		t.Fatalf("RestartApp: %v", err)
+	}
+	if err := c.UpdateContainerHTTPPort("api", 3000); err != nil {
+		t.Fatalf("UpdateContainerHTTPPort: %v", err)
+	}
+
+	app, _ := srv.App("api")
+	if app.ContainerHTTPPort != 3000 {
+		t.Errorf("port is %d, want 3000", app.ContainerHTTPPort)
+	}
+	if app.InstanceCount != 2 || len(app.Volumes) != 1 || len(app.Ports) != 1 || len(app.EnvVars) != 1 || app.ServiceUpdateOverride == "" || app.HTTPAuth == nil {
+		t.Errorf("settings were reset: %+v", app)
+	}
+	if app.HTTPAuth != nil && app.HTTPAuth.PasswordHashed != crapitest.HashPassword("s3cret") {
+		t.Errorf("HTTP auth password changed: %+v", app.HTTPAuth)
+	}
+
+	if err := c.SetHTTPAuth("api", "ops", "n3w"); err != nil {
+		t.Fatalf("SetHTTPAuth: %v", err)
+	}
+	if app, _ := srv.App("api"); app.HTTPAuth == nil || app.HTTPAuth.User != "ops" || app.HTTPAuth.PasswordHashed != crapitest.HashPassword("n3w") || app.ContainerHTTPPort != 3000 {
+		t.Errorf("HTTP auth not set or settings reset: %+v", app)
+	}
+	if err := c.ClearHTTPAuth("api"); err != nil {
+		t.Fatalf("ClearHTTPAuth: %v", err)
+	}
+	if app, _ := srv.App("api"); app.HTTPAuth != nil || app.InstanceCount != 2 {
+		t.Errorf("HTTP auth not cleared or settings reset: %+v", app)
+	}
+}
+
+func TestConcurrentPatchesOfOneApp(t *testing.T) {
+	srv := crapitest.NewServer("", crapi.AppDefinition{AppName: "api"})
+	defer srv.Close()
+
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, mutate := range []func(string) error{c.EnableWebsocketSupport, c.EnableForceHTTPS, c.TurnInstanceCountZero} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := mutate("api"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	app, _ := srv.App("api")
+	if !app.WebsocketSupport || !app.ForceSsl || app.InstanceCount != 0 {
+		t.Errorf("a patch was lost: %+v", app)
+	}
+}
+
+func TestUpdatesKeepUnknownFields(t *testing.T) {
+	srv := crapitest.NewServer("", crapi.AppDefinition{AppName: "api", AppDeployTokenConfig: crapi.AppDeployTokenConfig{Enabled: true, AppDeployToken: "t0k3n"}})
+	defer srv.Close()
+
+	// settings of newer CapRover versions crapi doesn't know about
+	srv.SetField("api", "healthCheck", map[string]string{"path": "/health", "interval": "10s"})
+	srv.SetField("api", "restartPolicy", "on-failure")
+	srv.SetField("api", "extraHosts", []map[string]string{{"host": "db.internal"}})
+	srv.SetField("api", "stopGracePeriod", 30)
+
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.EnableWebsocketSupport("api"); err != nil {
+		t.Fatalf("EnableWebsocketSupport: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"healthCheck":     `{"interval":"10s","path":"/health"}`,
+		"restartPolicy":   `"on-failure"`,
+		"extraHosts":      `[{"host":"db.internal"}]`,
+		"stopGracePeriod": `30`,
+	} {
+		got, ok := srv.Field("api", key)
+		if !ok {
+			t.Errorf("%s was dropped by the update", key)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s is %s, want %s", key, got, want)
+		}
+	}
+
+	app, _ := srv.App("api")
+	if !app.WebsocketSupport || app.AppDeployTokenConfig.AppDeployToken != "t0k3n" {
+		t.Errorf("known fields not updated or lost: %+v", app)
+	}
+}
+
+func TestUpdateResourceConstraintKeepsOverride(t *testing.T) {
+	srv := crapitest.NewServer("", crapi.AppDefinition{
+		AppName:               "api",
+		ServiceUpdateOverride: `{"TaskTemplate":{"Placement":{"Constraints":["node.role==worker"]},"Resources":{"Reservations":{"MemoryBytes":1048576}}},"Mode":{"Replicated":{}}}`,
+	})
+	defer srv.Close()
+
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpdateResourceConstraint("api", 512, 0.5); err != nil {
+		t.Fatalf("UpdateResourceConstraint: %v", err)
+	}
+
+	app, _ := srv.App("api")
+	want := `Mode:
+    Replicated: {}
+TaskTemplate:
+    Placement:
+        Constraints:
+            - node.role==worker
+    Resources:
+        Limits:
+            MemoryBytes: 536870912
+            NanoCPUs: 500000000
+        Reservations:
+            MemoryBytes: 1048576
+`
+	if app.ServiceUpdateOverride != want {
+		t.Errorf("got override\n%s\nwant\n%s", app.ServiceUpdateOverride, want)
+	}
+
+	memory := int64(0)
+	if err := c.UpdateResourceLimits("api", crapi.ResourceLimits{MemoryBytes: &memory}); err != nil {
+		t.Fatalf("UpdateResourceLimits: %v", err)
+	}
+	app, _ = srv.App("api")
+	if strings.Contains(app.ServiceUpdateOverride, "536870912") || !strings.Contains(app.ServiceUpdateOverride, "NanoCPUs: 500000000") {
+		t.Errorf("only the memory limit should be removed:\n%s", app.ServiceUpdateOverride)
+	}
+}
@@ -0,0 +1,140 @@
+package crapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ProjectDefinition holds a project, which groups apps. Projects can be
+// nested with ParentProjectID.
+type ProjectDefinition struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	ParentProjectID string `json:"parentProjectId,omitempty"`
+	Description     string `json:"description"`
+}
+
+// ProjectsResponse holds the response of the project list endpoint.
+type ProjectsResponse struct {
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+	Data        struct {
+		Projects []ProjectDefinition `json:"projects"`
+	} `json:"data"`
+}
+
+// GetProjects returns all the projects of the instance.
+func (c *Caprover) GetProjects() ([]ProjectDefinition, error) {
+	body, err := c.doRequest("GET", c.buildURL(URLProjectsPath), nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsp ProjectsResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	if rsp.Status != StatusOK {
+		return nil, errors.New(rsp.Description)
+	}
+
+	return rsp.Data.Projects, nil
+}
+
+// CreateProject creates a project, nested in the project parentProjectID
+// unless it is empty.
+func (c *Caprover) CreateProject(name string, parentProjectID string, description string) error {
+	return c.postProjects(URLProjectRegisterPath, map[string]string{
+		"name":            name,
+		"parentProjectId": parentProjectID,
+		"description":     description,
+	})
+}
+
+// UpdateProject renames, moves or describes a project.
+func (c *Caprover) UpdateProject(project ProjectDefinition) error {
+	return c.postProjects(URLProjectUpdatePath, map[string]any{
+		"projectDefinition": project,
+	})
+}
+
+// DeleteProjects deletes projects. CapRover refuses to delete projects that
+// still hold apps or other projects.
+func (c *Caprover) DeleteProjects(projectIDs ...string) error {
+	return c.postProjects(URLProjectDeletePath, map[string]any{
+		"projectIds": append([]string{}, projectIDs...),
+	})
+}
+
+func (c *Caprover) postProjects(path string, data any) error {
+	jsonEncode, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshaling request data: %w", err)
+	}
+
+	body, err := c.doRequest("POST", c.buildURL(path), jsonEncode, -1)
+	if err != nil {
+		return err
+	}
+
+	var rsp GenericAppResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	if rsp.Status != StatusOK {
+		return errors.New(rsp.Description)
+	}
+
+	return nil
+}
+
+// ProjectPath returns the names of a project and its parents joined by
+// slashes, e.g. "shop/payments", or "" when the project doesn't exist.
+func ProjectPath(projects []ProjectDefinition, projectID string) string {
+	return strings.Join(ProjectNames(projects, projectID), "/")
+}
+
+// ProjectNames returns the names of a project and its parents, outermost
+// first, or nil when the project doesn't exist.
+func ProjectNames(projects []ProjectDefinition, projectID string) []string {
+	byID := make(map[string]ProjectDefinition, len(projects))
+	for _, p := range projects {
+		byID[p.ID] = p
+	}
+
+	var names []string
+	for id := projectID; id != ""; {
+		p, ok := byID[id]
+		if !ok || len(names) > len(projects) {
+			// unknown parent or a cycle
+			return nil
+		}
+		names = append([]string{p.Name}, names...)
+		id = p.ParentProjectID
+	}
+
+	return names
+}
+
+// FindProject returns the project at path, see ProjectPath.
+func FindProject(projects []ProjectDefinition, path string) (ProjectDefinition, bool) {
+	var found ProjectDefinition
+	parent := ""
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		ok := false
+		for _, p := range projects {
+			if p.Name == name && p.ParentProjectID == parent {
+				found, ok = p, true
+				break
+			}
+		}
+		if !ok {
+			return ProjectDefinition{}, false
+		}
+		parent = found.ID
+	}
+
+	return found, true
+}
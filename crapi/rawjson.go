@@ -0,0 +1,90 @@
+package crapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// UnmarshalJSON decodes an app definition and keeps its raw JSON in Raw.
+func (d *AppDefinition) UnmarshalJSON(data []byte) error {
+	type plain AppDefinition
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+
+	d.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes an update request. When the request carries the raw
+// JSON of the app definition it was made from, the fields of the request are
+// merged into it, so settings crapi doesn't know about are sent back as they
+// are instead of being reset by CapRover.
+func (r UpdateAppRequest) MarshalJSON() ([]byte, error) {
+	type plain UpdateAppRequest
+	known, err := json.Marshal(plain(r))
+	if err != nil || len(r.Raw) == 0 {
+		return known, err
+	}
+
+	base, err := decodeJSON(r.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding raw app definition: %w", err)
+	}
+	overlay, err := decodeJSON(known)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeJSON(base, overlay))
+}
+
+// Supports reports whether the app definition the request was made from has
+// the given JSON field, i.e. whether the CapRover version knows the setting.
+// Without Raw it assumes so.
+func (r UpdateAppRequest) Supports(field string) bool {
+	if len(r.Raw) == 0 {
+		return true
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(r.Raw, &fields); err != nil {
+		return false
+	}
+	_, ok := fields[field]
+	return ok
+}
+
+// decodeJSON decodes JSON into generic values, keeping numbers as they are.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// mergeJSON merges overlay into base. Objects are merged key by key, so keys
+// only present in base are kept; any other overlay value replaces the base
+// value, arrays included.
+func mergeJSON(base any, overlay any) any {
+	baseObj, ok := base.(map[string]any)
+	if !ok {
+		return overlay
+	}
+	overlayObj, ok := overlay.(map[string]any)
+	if !ok {
+		return overlay
+	}
+
+	for key, value := range overlayObj {
+		baseObj[key] = mergeJSON(baseObj[key], value)
+	}
+
+	return baseObj
+}
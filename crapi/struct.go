@@ -16,7 +16,10 @@
 
 package crapi
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // LoginResponse holds the response from the login endpoint. It contains the token information.
 type LoginResponse struct {
@@ -30,7 +33,8 @@ type LoginResponse struct {
 // VolumeInformation holds a single persistant directory info for a given app.
 type VolumeInformation struct {
 	ContainerPath string `json:"containerPath"`
-	VolumeName    string `json:"volumeName"`
+	VolumeName    string `json:"volumeName,omitempty"`
+	HostPath      string `json:"hostPath,omitempty"`
 }
 
 // PortInformation holds a single port mapping info for a given app.
@@ -59,44 +63,76 @@ type EnvVarInformation struct {
 	Value string `json:"value"`
 }
 
+// AppVersion holds a single deployed version of a given app.
+type AppVersion struct {
+	Version           int       `json:"version"`
+	TimeStamp         time.Time `json:"timeStamp"`
+	DeployedImageName string    `json:"deployedImageName"`
+	GitHash           string    `json:"gitHash"`
+}
+
+// AppDeployTokenConfig holds the deploy token of an app, which allows
+// deploying that app only. CapRover generates a new token when it is enabled
+// without one.
 type AppDeployTokenConfig struct {
-	Enabled bool `json:"enabled"`
+	Enabled        bool   `json:"enabled"`
+	AppDeployToken string `json:"appDeployToken"`
+}
+
+// CustomDomain holds a custom domain of an app and whether it is served
+// over HTTPS.
+type CustomDomain struct {
+	PublicDomain string `json:"publicDomain"`
+	HasSsl       bool   `json:"hasSsl"`
+}
+
+// HTTPAuth holds the HTTP basic auth protecting an app. CapRover only
+// returns the hash of the password; set Password to change it, or send the
+// hash back to keep the current one.
+type HTTPAuth struct {
+	User           string `json:"user"`
+	Password       string `json:"password,omitempty"`
+	PasswordHashed string `json:"passwordHashed,omitempty"`
 }
 
 // AppDefinition holds all the information stored by the caprover for a given app.
 type AppDefinition struct {
-	HasPersistentData                 bool                `json:"hasPersistentData"`
-	Description                       string              `json:"description"`
-	InstanceCount                     int                 `json:"instanceCount"`
-	CaptainDefinitionRelativeFilePath string              `json:"captainDefinitionRelativeFilePath"`
-	Networks                          []string            `json:"networks"`
-	EnvVars                           []EnvVarInformation `json:"envVars"`
-	Volumes                           []VolumeInformation `json:"volumes"`
-	Ports                             []PortInformation   `json:"ports"`
-	Versions                          []struct {
-		Version           int       `json:"version"`
-		TimeStamp         time.Time `json:"timeStamp"`
-		DeployedImageName string    `json:"deployedImageName"`
-		GitHash           string    `json:"gitHash"`
-	} `json:"versions"`
-	DeployedVersion        int                  `json:"deployedVersion"`
-	NotExposeAsWebApp      bool                 `json:"notExposeAsWebApp"`
-	CustomDomain           []any                `json:"customDomain"`
-	HasDefaultSubDomainSsl bool                 `json:"hasDefaultSubDomainSsl"`
-	ForceSsl               bool                 `json:"forceSsl"`
-	WebsocketSupport       bool                 `json:"websocketSupport"`
-	ContainerHTTPPort      int                  `json:"containerHttpPort"`
-	NodeID                 string               `json:"nodeId,omitempty"`
-	PreDeployFunction      string               `json:"preDeployFunction"`
-	ServiceUpdateOverride  string               `json:"serviceUpdateOverride"`
-	AppDeployTokenConfig   AppDeployTokenConfig `json:"appDeployTokenConfig"`
-	AppName                string               `json:"appName"`
-	IsAppBuilding          bool                 `json:"isAppBuilding"`
-	AppPushWebhook         struct {
+	HasPersistentData                 bool                 `json:"hasPersistentData"`
+	Description                       string               `json:"description"`
+	InstanceCount                     int                  `json:"instanceCount"`
+	CaptainDefinitionRelativeFilePath string               `json:"captainDefinitionRelativeFilePath"`
+	Networks                          []string             `json:"networks"`
+	EnvVars                           []EnvVarInformation  `json:"envVars"`
+	Volumes                           []VolumeInformation  `json:"volumes"`
+	Ports                             []PortInformation    `json:"ports"`
+	Versions                          []AppVersion         `json:"versions"`
+	DeployedVersion                   int                  `json:"deployedVersion"`
+	NotExposeAsWebApp                 bool                 `json:"notExposeAsWebApp"`
+	CustomDomain                      []CustomDomain       `json:"customDomain"`
+	RedirectDomain                    string               `json:"redirectDomain,omitempty"`
+	ProjectID                         string               `json:"projectId,omitempty"`
+	Tags                              []AppTag             `json:"tags"`
+	HasDefaultSubDomainSsl            bool                 `json:"hasDefaultSubDomainSsl"`
+	ForceSsl                          bool                 `json:"forceSsl"`
+	WebsocketSupport                  bool                 `json:"websocketSupport"`
+	ContainerHTTPPort                 int                  `json:"containerHttpPort"`
+	NodeID                            string               `json:"nodeId,omitempty"`
+	PreDeployFunction                 string               `json:"preDeployFunction"`
+	ServiceUpdateOverride             string               `json:"serviceUpdateOverride"`
+	CustomNginxConfig                 string               `json:"customNginxConfig"`
+	AppDeployTokenConfig              AppDeployTokenConfig `json:"appDeployTokenConfig"`
+	HTTPAuth                          *HTTPAuth            `json:"httpAuth,omitempty"`
+	AppName                           string               `json:"appName"`
+	IsAppBuilding                     bool                 `json:"isAppBuilding"`
+	AppPushWebhook                    struct {
 		TokenVersion     string      `json:"tokenVersion"`
 		PushWebhookToken string      `json:"pushWebhookToken"`
 		RepoInfo         AppRepoInfo `json:"repoInfo"`
 	} `json:"appPushWebhook,omitempty"`
+
+	// Raw is the app definition as CapRover returned it, including the
+	// fields crapi doesn't model.
+	Raw json.RawMessage `json:"-"`
 }
 
 // ListAppResponse holds the response for the list all app request.
@@ -120,22 +156,41 @@ type GenericAppResponse struct {
 
 // UpdateAppRequest holds response for update app request
 type UpdateAppRequest struct {
-	AppName                           string               `json:"appName"`
-	InstanceCount                     int                  `json:"instanceCount"`
-	CaptainDefinitionRelativeFilePath string               `json:"captainDefinitionRelativeFilePath"`
-	NotExposeAsWebApp                 bool                 `json:"notExposeAsWebApp"`
-	ForceSsl                          bool                 `json:"forceSsl"`
-	WebsocketSupport                  bool                 `json:"websocketSupport"`
-	Volumes                           []VolumeInformation  `json:"volumes"`
-	Ports                             []PortInformation    `json:"ports"`
-	AppPushWebhook                    AppPushWebHook       `json:"appPushWebhook"`
-	NodeID                            string               `json:"nodeId"`
-	PreDeployFunction                 string               `json:"preDeployFunction"`
-	ServiceUpdateOverride             string               `json:"serviceUpdateOverride"`
-	ContainerHTTPPort                 int                  `json:"containerHttpPort"`
-	Description                       string               `json:"description"`
-	EnvVars                           []EnvVarInformation  `json:"envVars"`
-	AppDeployTokenConfig              AppDeployTokenConfig `json:"appDeployTokenConfig"`
+	AppName                           string              `json:"appName"`
+	InstanceCount                     int                 `json:"instanceCount"`
+	CaptainDefinitionRelativeFilePath string              `json:"captainDefinitionRelativeFilePath"`
+	NotExposeAsWebApp                 bool                `json:"notExposeAsWebApp"`
+	ForceSsl                          bool                `json:"forceSsl"`
+	WebsocketSupport                  bool                `json:"websocketSupport"`
+	Volumes                           []VolumeInformation `json:"volumes"`
+	Ports                             []PortInformation   `json:"ports"`
+	AppPushWebhook                    AppPushWebHook      `json:"appPushWebhook"`
+	NodeID                            string              `json:"nodeId"`
+	// Networks are sent back as they are, CapRover doesn't update them.
+	Networks              []string             `json:"networks"`
+	PreDeployFunction     string               `json:"preDeployFunction"`
+	ServiceUpdateOverride string               `json:"serviceUpdateOverride"`
+	CustomNginxConfig     string               `json:"customNginxConfig"`
+	ContainerHTTPPort     int                  `json:"containerHttpPort"`
+	Description           string               `json:"description"`
+	EnvVars               []EnvVarInformation  `json:"envVars"`
+	AppDeployTokenConfig  AppDeployTokenConfig `json:"appDeployTokenConfig"`
+	// HTTPAuth is always sent, nil removes the HTTP basic auth of the app.
+	HTTPAuth *HTTPAuth `json:"httpAuth"`
+	// RedirectDomain is the domain every other domain of the app redirects
+	// to, empty for none.
+	RedirectDomain string `json:"redirectDomain"`
+	// ProjectID is the project the app belongs to, empty for none.
+	ProjectID string `json:"projectId"`
+	// Tags are sent as they are, see Tags for labels. Instances without
+	// tags ignore them.
+	Tags []AppTag `json:"tags"`
+
+	// Raw is the definition of the app the request was made from, see
+	// AppDefinition.Raw. The fields of the request are merged into it when
+	// the request is encoded, so unknown settings are kept. Leave it empty
+	// to send only the fields above.
+	Raw json.RawMessage `json:"-"`
 }
 
 // CustomAppRepositoryConfig holds custom app repository information.
@@ -172,7 +227,9 @@ type AppBuildLogLogs struct {
 
 // AppBuildLogData is a data bucket for AppBuildLogLogs
 type AppBuildLogData struct {
-	Logs AppBuildLogLogs `json:"logs"`
+	IsAppBuilding bool            `json:"isAppBuilding"`
+	IsBuildFailed bool            `json:"isBuildFailed"`
+	Logs          AppBuildLogLogs `json:"logs"`
 }
 
 // AppBuildLogResponse is a response bucket for AppBuildLogData
@@ -0,0 +1,65 @@
+package crapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// AppTag holds a tag of an app. letgofur stores labels in tags as
+// "key=value", see Labels.
+type AppTag struct {
+	TagName string `json:"tagName"`
+}
+
+// Labels returns the labels stored in tags. A tag without "=" is a label
+// with an empty value.
+func Labels(tags []AppTag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag.TagName, "=")
+		labels[key] = value
+	}
+
+	return labels
+}
+
+// DuplicateLabels returns the sorted keys stored in more than one tag, e.g.
+// "team=a" and "team=b". Labels keeps only the last value of such keys.
+func DuplicateLabels(tags []AppTag) []string {
+	seen := make(map[string]int, len(tags))
+	var keys []string
+	for _, tag := range tags {
+		key, _, _ := strings.Cut(tag.TagName, "=")
+		if seen[key]++; seen[key] == 2 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Tags returns the tags storing labels, sorted by key. It is never nil, so
+// an update without labels removes the tags.
+func Tags(labels map[string]string) []AppTag {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tags := make([]AppTag, 0, len(keys))
+	for _, key := range keys {
+		name := key
+		if value := labels[key]; value != "" {
+			name += "=" + value
+		}
+		tags = append(tags, AppTag{TagName: name})
+	}
+
+	return tags
+}
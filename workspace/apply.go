@@ -0,0 +1,617 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pararang/letgofur/crapi"
+	"gopkg.in/yaml.v3"
+)
+
+// Client is the part of the CapRover API needed to apply configurations.
+// *crapi.Caprover implements it.
+type Client interface {
+	GetDefaultUpdateRequest(appName string) (crapi.UpdateAppRequest, error)
+	PatchApp(appName string, patch crapi.PatchFunc) error
+	GetNodes() ([]crapi.Node, error)
+	GetProjects() ([]crapi.ProjectDefinition, error)
+}
+
+// ErrNodeNotFound is returned when a configuration pins an app to a node the
+// cluster doesn't have.
+var ErrNodeNotFound = errors.New("node not found")
+
+// NodeNone as the Node of a configuration unpins the app from its node.
+const NodeNone = "none"
+
+// NginxDefault as the Nginx of a configuration resets the app to the NGINX
+// template of the instance.
+const NginxDefault = "default"
+
+// Change describes a single setting that differs between a configuration and
+// the app on CapRover.
+type Change struct {
+	Field string
+	From  string
+	To    string
+
+	// Old and New hold the whole values of multi-line settings, which From
+	// and To only summarize.
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, c.From, c.To)
+}
+
+// Reconcile overrides the current settings of an app with the ones defined in
+// config and returns the resulting update request with the list of changes.
+// Settings that config leaves unset keep their current value. config.Node
+// must be a node ID, Plan and Apply resolve hostnames first. The project is
+// left to Plan and Apply too, which look it up by name.
+func Reconcile(current crapi.UpdateAppRequest, config AppConfig) (crapi.UpdateAppRequest, []Change, error) {
+	var changes []Change
+
+	// Update instance count
+	if config.Instances != nil && *config.Instances != current.InstanceCount {
+		changes = append(changes, Change{
+			Field: "Instances",
+			From:  fmt.Sprint(current.InstanceCount),
+			To:    fmt.Sprint(*config.Instances),
+		})
+		current.InstanceCount = *config.Instances
+	}
+
+	// an override written differently but with the same resources isn't a
+	// change
+	if HasResourceConstraints(&config.Resources) && !sameResources(current.ServiceUpdateOverride, config.Resources) {
+		suo, err := MergeResources(current.ServiceUpdateOverride, config.Resources)
+		if err != nil {
+			return crapi.UpdateAppRequest{}, nil, err
+		}
+
+		changes = append(changes, Change{
+			Field: "Resources",
+			From:  describeResources(current.ServiceUpdateOverride),
+			To:    describeResources(suo),
+		})
+		current.ServiceUpdateOverride = suo
+	}
+
+	if config.Description != nil && *config.Description != current.Description {
+		changes = append(changes, Change{
+			Field: "Description",
+			From:  orNone(current.Description),
+			To:    orNone(*config.Description),
+		})
+		current.Description = *config.Description
+	}
+
+	if config.NotExposeAsWebApp != nil && *config.NotExposeAsWebApp != current.NotExposeAsWebApp {
+		changes = append(changes, Change{
+			Field: "NotExposeAsWebApp",
+			From:  fmt.Sprint(current.NotExposeAsWebApp),
+			To:    fmt.Sprint(*config.NotExposeAsWebApp),
+		})
+		current.NotExposeAsWebApp = *config.NotExposeAsWebApp
+	}
+
+	if config.ContainerHTTPPort > 0 && config.ContainerHTTPPort != current.ContainerHTTPPort {
+		changes = append(changes, Change{
+			Field: "ContainerHTTPPort",
+			From:  fmt.Sprint(current.ContainerHTTPPort),
+			To:    fmt.Sprint(config.ContainerHTTPPort),
+		})
+		current.ContainerHTTPPort = config.ContainerHTTPPort
+	}
+
+	if config.WebsocketSupport != nil && *config.WebsocketSupport != current.WebsocketSupport {
+		changes = append(changes, Change{
+			Field: "WebsocketSupport",
+			From:  fmt.Sprint(current.WebsocketSupport),
+			To:    fmt.Sprint(*config.WebsocketSupport),
+		})
+		current.WebsocketSupport = *config.WebsocketSupport
+	}
+
+	if config.ForceSsl != nil && *config.ForceSsl != current.ForceSsl {
+		changes = append(changes, Change{
+			Field: "ForceSsl",
+			From:  fmt.Sprint(current.ForceSsl),
+			To:    fmt.Sprint(*config.ForceSsl),
+		})
+		current.ForceSsl = *config.ForceSsl
+	}
+
+	if config.RedirectDomain != nil && *config.RedirectDomain != current.RedirectDomain {
+		changes = append(changes, Change{
+			Field: "RedirectDomain",
+			From:  orNone(current.RedirectDomain),
+			To:    orNone(*config.RedirectDomain),
+		})
+		current.RedirectDomain = *config.RedirectDomain
+	}
+
+	if path := config.CaptainDefinitionRelativeFilePath; path != "" && path != current.CaptainDefinitionRelativeFilePath {
+		changes = append(changes, Change{
+			Field: "CaptainDefinitionRelativeFilePath",
+			From:  orNone(current.CaptainDefinitionRelativeFilePath),
+			To:    path,
+		})
+		current.CaptainDefinitionRelativeFilePath = path
+	}
+
+	if config.Nginx != "" {
+		nginx, err := readNginx(config)
+		if err != nil {
+			return crapi.UpdateAppRequest{}, nil, err
+		}
+
+		if nginx != current.CustomNginxConfig {
+			changes = append(changes, Change{
+				Field: "Nginx",
+				From:  describeTemplate(current.CustomNginxConfig),
+				To:    describeTemplate(nginx),
+				Old:   current.CustomNginxConfig,
+				New:   nginx,
+			})
+			current.CustomNginxConfig = nginx
+		}
+	}
+
+	if config.PreDeploy != "" {
+		script, err := readPreDeploy(config)
+		if err != nil {
+			return crapi.UpdateAppRequest{}, nil, err
+		}
+
+		if script != current.PreDeployFunction {
+			changes = append(changes, Change{
+				Field: "PreDeploy",
+				From:  describeScript(current.PreDeployFunction),
+				To:    describeScript(script),
+				Old:   current.PreDeployFunction,
+				New:   script,
+			})
+			current.PreDeployFunction = script
+		}
+	}
+
+	if config.Git != nil {
+		repo, gitChanges, err := reconcileGit(current.AppPushWebhook.RepoInfo, *config.Git, config.Dir)
+		if err != nil {
+			return crapi.UpdateAppRequest{}, nil, err
+		}
+		changes = append(changes, gitChanges...)
+		current.AppPushWebhook.RepoInfo = repo
+	}
+
+	if config.HTTPAuth != nil {
+		auth, authChanges, err := reconcileHTTPAuth(current.HTTPAuth, *config.HTTPAuth, config.Dir)
+		if err != nil {
+			return crapi.UpdateAppRequest{}, nil, err
+		}
+		changes = append(changes, authChanges...)
+		current.HTTPAuth = auth
+	}
+
+	if node := config.Node; node != "" {
+		if node == NodeNone {
+			node = ""
+		}
+		if node != current.NodeID {
+			changes = append(changes, Change{Field: "Node", From: orNone(current.NodeID), To: orNone(node)})
+			current.NodeID = node
+		}
+	}
+
+	// CapRover's update endpoint doesn't take the networks, changing them
+	// would show up as a change on every apply
+	if config.Networks != nil && !slices.Equal(config.Networks, current.Networks) {
+		return crapi.UpdateAppRequest{}, nil, fmt.Errorf("networks can't be changed through the CapRover API, they are %s", orNone(strings.Join(current.Networks, ", ")))
+	}
+
+	// instances without tags keep the labels in the workspace only
+	if config.Labels != nil && current.Supports("tags") && !maps.Equal(config.Labels, crapi.Labels(current.Tags)) {
+		changes = append(changes, Change{
+			Field: "Labels",
+			From:  describeLabels(crapi.Labels(current.Tags)),
+			To:    describeLabels(config.Labels),
+		})
+		current.Tags = crapi.Tags(config.Labels)
+	}
+
+	// volumes, ports, environment variables and the deploy token aren't part
+	// of the workspace, current keeps them as they are
+
+	return current, changes, nil
+}
+
+// Plan returns the changes Apply would make, without making them, and
+// warnings about the node config pins the app to: a node that doesn't exist,
+// or that doesn't accept new tasks. Unlike Apply, it doesn't fail when the
+// node doesn't exist.
+func Plan(client Client, config AppConfig) ([]Change, []string, error) {
+	_, changes, warnings, err := reconcile(client, config, false)
+	return changes, warnings, err
+}
+
+// Apply reconciles the app described by config with CapRover. Nothing is sent
+// when the app already matches. It returns the applied changes.
+//
+// The app is read and updated with PatchApp, so an Apply doesn't undo a patch
+// of the same app made at the same time through the same client.
+func Apply(client Client, config AppConfig) ([]Change, error) {
+	config, _, err := resolveConfig(client, config, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	var reconcileErr error
+	err = client.PatchApp(config.AppName, func(req *crapi.UpdateAppRequest) error {
+		updated, c, err := reconcileCurrent(client, *req, config)
+		if err != nil {
+			reconcileErr = err
+			return err
+		}
+		if len(c) == 0 {
+			return errUpToDate
+		}
+
+		*req, changes = updated, c
+		return nil
+	})
+	switch {
+	case reconcileErr != nil:
+		return nil, reconcileErr
+	case errors.Is(err, errUpToDate):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("error updating app configuration: %w", err)
+	}
+
+	return changes, nil
+}
+
+// errUpToDate cancels the update of an app that already matches its
+// configuration.
+var errUpToDate = errors.New("app is up to date")
+
+// reconcile overrides the current settings of an app with config, looking up
+// the node and project config refers to, and returns warnings about the node.
+// A missing node is only an error when strict.
+func reconcile(client Client, config AppConfig, strict bool) (crapi.UpdateAppRequest, []Change, []string, error) {
+	config, warnings, err := resolveConfig(client, config, strict)
+	if err != nil {
+		return crapi.UpdateAppRequest{}, nil, nil, err
+	}
+
+	// Get the current app configuration then override it with the new one defined in the config file
+	current, err := client.GetDefaultUpdateRequest(config.AppName)
+	if err != nil {
+		return crapi.UpdateAppRequest{}, nil, nil, fmt.Errorf("error getting current app configuration: %w", err)
+	}
+
+	updated, changes, err := reconcileCurrent(client, current, config)
+	if err != nil {
+		return crapi.UpdateAppRequest{}, nil, nil, err
+	}
+
+	return updated, changes, warnings, nil
+}
+
+// resolveConfig resolves the node config pins the app to and returns warnings
+// about it. A missing node is only an error when strict.
+func resolveConfig(client Client, config AppConfig, strict bool) (AppConfig, []string, error) {
+	name := config.Node
+	config, node, err := resolveNode(client, config)
+	if err != nil && (strict || !errors.Is(err, ErrNodeNotFound)) {
+		return config, nil, err
+	}
+
+	return config, nodeWarnings(name, node), nil
+}
+
+// reconcileCurrent overrides current, the settings of an app, with config,
+// looking up the project config refers to.
+func reconcileCurrent(client Client, current crapi.UpdateAppRequest, config AppConfig) (crapi.UpdateAppRequest, []Change, error) {
+	updated, changes, err := Reconcile(current, config)
+	if err != nil {
+		return crapi.UpdateAppRequest{}, nil, err
+	}
+
+	if config.Project != nil {
+		projects, err := client.GetProjects()
+		if err != nil {
+			return crapi.UpdateAppRequest{}, nil, fmt.Errorf("error getting projects: %w", err)
+		}
+
+		change, err := reconcileProject(projects, &updated, *config.Project)
+		if err != nil {
+			return crapi.UpdateAppRequest{}, nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	return updated, changes, nil
+}
+
+// reconcileProject moves an app to the project at path, see crapi.ProjectPath.
+func reconcileProject(projects []crapi.ProjectDefinition, req *crapi.UpdateAppRequest, path string) (*Change, error) {
+	id := ""
+	if path != "" {
+		project, ok := crapi.FindProject(projects, path)
+		if !ok {
+			return nil, fmt.Errorf("project %s not found, create it with 'letgofur project create %s'", path, path)
+		}
+		id = project.ID
+	}
+
+	if id == req.ProjectID {
+		return nil, nil
+	}
+
+	change := &Change{
+		Field: "Project",
+		From:  orNone(crapi.ProjectPath(projects, req.ProjectID)),
+		To:    orNone(path),
+	}
+	req.ProjectID = id
+
+	return change, nil
+}
+
+// resolveNode replaces the node hostname of config with the node's ID and
+// returns the node, nil when config doesn't pin the app or the node doesn't
+// exist.
+func resolveNode(client Client, config AppConfig) (AppConfig, *crapi.Node, error) {
+	if config.Node == "" || config.Node == NodeNone {
+		return config, nil, nil
+	}
+
+	nodes, err := client.GetNodes()
+	if err != nil {
+		return config, nil, fmt.Errorf("error getting cluster nodes: %w", err)
+	}
+
+	node, ok := crapi.FindNode(nodes, config.Node)
+	if !ok {
+		return config, nil, fmt.Errorf("%w: %s", ErrNodeNotFound, config.Node)
+	}
+	config.Node = node.NodeID
+
+	return config, &node, nil
+}
+
+// nodeWarnings returns the problems with the node named name an app is pinned
+// to, node being nil when it doesn't exist.
+func nodeWarnings(name string, node *crapi.Node) []string {
+	switch {
+	case name == "" || name == NodeNone:
+		return nil
+	case node == nil:
+		return []string{fmt.Sprintf("node %s doesn't exist, apply will fail", name)}
+	case node.Status == crapi.NodeDrain:
+		return []string{fmt.Sprintf("node %s is drained, the app won't run on it", name)}
+	case node.Status == crapi.NodePause:
+		return []string{fmt.Sprintf("node %s is paused, the app won't be scheduled on it", name)}
+	case node.State != "" && node.State != "ready":
+		return []string{fmt.Sprintf("node %s is %s", name, node.State)}
+	}
+
+	return nil
+}
+
+// reconcileGit overrides the repository settings. Secrets are resolved from
+// their references and never show up in the changes.
+func reconcileGit(current crapi.AppRepoInfo, git GitConfig, dir string) (crapi.AppRepoInfo, []Change, error) {
+	var changes []Change
+
+	for _, field := range []struct {
+		name    string
+		current *string
+		want    string
+	}{
+		{"Git.Repo", &current.Repo, git.Repo},
+		{"Git.Branch", &current.Branch, git.Branch},
+		{"Git.User", &current.User, git.User},
+	} {
+		if *field.current != field.want {
+			changes = append(changes, Change{Field: field.name, From: orNone(*field.current), To: orNone(field.want)})
+			*field.current = field.want
+		}
+	}
+
+	for _, secret := range []struct {
+		name    string
+		current *string
+		ref     SecretRef
+	}{
+		{"Git.Password", &current.Password, git.Password},
+		{"Git.SSHKey", &current.SSHKey, git.SSHKey},
+	} {
+		if secret.ref.IsRedacted() {
+			continue
+		}
+
+		value, err := secret.ref.Resolve(dir)
+		if err != nil {
+			return current, nil, fmt.Errorf("invalid %s: %w", secret.name, err)
+		}
+
+		if secret.name == "Git.Password" {
+			// password files usually end with a newline that isn't part of it
+			value = strings.TrimRight(value, "\r\n")
+		}
+
+		if *secret.current != value {
+			to := describeSecret(value)
+			if *secret.current != "" && value != "" {
+				to = "changed"
+			}
+			changes = append(changes, Change{Field: secret.name, From: describeSecret(*secret.current), To: to})
+			*secret.current = value
+		}
+	}
+
+	return current, changes, nil
+}
+
+// reconcileHTTPAuth overrides the HTTP basic auth. The password is only sent
+// when the auth is added or its user changes, see HTTPAuthConfig.
+func reconcileHTTPAuth(current *crapi.HTTPAuth, auth HTTPAuthConfig, dir string) (*crapi.HTTPAuth, []Change, error) {
+	currentUser := ""
+	if current != nil {
+		currentUser = current.User
+	}
+
+	if auth.User == "" {
+		if current == nil {
+			return nil, nil, nil
+		}
+		return nil, []Change{{Field: "HTTPAuth.User", From: currentUser, To: "none"}}, nil
+	}
+
+	if auth.User == currentUser {
+		return current, nil, nil
+	}
+
+	changes := []Change{{Field: "HTTPAuth.User", From: orNone(currentUser), To: auth.User}}
+
+	if auth.Password.IsRedacted() {
+		if current == nil {
+			return nil, nil, fmt.Errorf("invalid HTTPAuth.Password: the app has no HTTP auth yet, use env:NAME or file:PATH")
+		}
+		// the hash is sent back, the password stays the same
+		return &crapi.HTTPAuth{User: auth.User, PasswordHashed: current.PasswordHashed}, changes, nil
+	}
+
+	password, err := auth.Password.Resolve(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid HTTPAuth.Password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return nil, nil, fmt.Errorf("invalid HTTPAuth.Password: a password is required")
+	}
+
+	to := "set"
+	if current != nil {
+		to = "changed"
+	}
+	changes = append(changes, Change{Field: "HTTPAuth.Password", From: describeSecret(currentUser), To: to})
+
+	return &crapi.HTTPAuth{User: auth.User, Password: password}, changes, nil
+}
+
+// describeTemplate summarizes a template for a Change.
+func describeTemplate(template string) string {
+	if template == "" {
+		return "default"
+	}
+	return fmt.Sprintf("custom (%d lines)", strings.Count(strings.TrimRight(template, "\n"), "\n")+1)
+}
+
+// describeLabels summarizes labels for a Change, sorted by key.
+func describeLabels(labels map[string]string) string {
+	var tags []string
+	for _, tag := range crapi.Tags(labels) {
+		tags = append(tags, tag.TagName)
+	}
+	return orNone(strings.Join(tags, ", "))
+}
+
+// describeScript summarizes a pre-deploy script for a Change.
+func describeScript(script string) string {
+	if script == "" {
+		return "none"
+	}
+	return fmt.Sprintf("script (%d lines)", strings.Count(strings.TrimRight(script, "\n"), "\n")+1)
+}
+
+func describeSecret(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return "set"
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// ResourcesOverride returns the ServiceUpdateOverride setting res, as Apply
+// writes it for an app without one.
+func ResourcesOverride(res Resources) (string, error) {
+	return MergeResources("", res)
+}
+
+// MergeResources returns a ServiceUpdateOverride with its resources set to
+// res. The resources res leaves unset are removed, the other settings of the
+// override are kept.
+func MergeResources(serviceUpdateOverride string, res Resources) (string, error) {
+	return crapi.EditServiceUpdateOverride(serviceUpdateOverride, func(root map[string]any) {
+		for key, r := range map[string]Resource{"Limits": res.Limits, "Reservations": res.Reservations} {
+			crapi.SetOverrideValue(root, r.MemoryBytes, "TaskTemplate", "Resources", key, "MemoryBytes")
+			crapi.SetOverrideValue(root, r.NanoCPUs, "TaskTemplate", "Resources", key, "NanoCPUs")
+		}
+	})
+}
+
+// HasResourceConstraints checks if the Resources structure has any constraints defined
+func HasResourceConstraints(res *Resources) bool {
+	if res == nil {
+		return false
+	}
+
+	return res.Limits.MemoryBytes != nil || res.Limits.NanoCPUs != nil ||
+		res.Reservations.MemoryBytes != nil || res.Reservations.NanoCPUs != nil
+}
+
+// sameResources reports whether a ServiceUpdateOverride sets res.
+func sameResources(serviceUpdateOverride string, res Resources) bool {
+	var suo ServiceUpdateOverride
+	if err := yaml.Unmarshal([]byte(serviceUpdateOverride), &suo); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(suo.TaskTemplate.Resources, res)
+}
+
+// describeResources summarizes the resources of a ServiceUpdateOverride.
+func describeResources(serviceUpdateOverride string) string {
+	if serviceUpdateOverride == "" {
+		return "none"
+	}
+
+	var suo ServiceUpdateOverride
+	if err := yaml.Unmarshal([]byte(serviceUpdateOverride), &suo); err != nil {
+		return "unparsable override"
+	}
+
+	res := suo.TaskTemplate.Resources
+	return fmt.Sprintf("limits %s, reservations %s", describeResource(res.Limits), describeResource(res.Reservations))
+}
+
+func describeResource(r Resource) string {
+	memory, cpus := "-", "-"
+	if r.MemoryBytes != nil {
+		memory = fmt.Sprintf("%dMB", *r.MemoryBytes/crapi.ResourceOneMb)
+	}
+	if r.NanoCPUs != nil {
+		cpus = fmt.Sprintf("%g CPU", float64(*r.NanoCPUs)/float64(crapi.ResourceOneCpu))
+	}
+
+	return fmt.Sprintf("memory %s / %s", memory, cpus)
+}
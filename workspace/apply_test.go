@@ -0,0 +1,46 @@
+package workspace_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pararang/letgofur/crapi"
+	"github.com/pararang/letgofur/crapi/crapitest"
+	"github.com/pararang/letgofur/workspace"
+)
+
+func TestApplyDoesntUndoConcurrentPatches(t *testing.T) {
+	srv := crapitest.NewServer("", crapi.AppDefinition{AppName: "api", InstanceCount: 1})
+	defer srv.Close()
+	// slow updates leave time for the other one to read stale settings
+	srv.Inject(crapitest.Fault{Path: crapi.URLUpdateAppPath, Latency: 10 * time.Millisecond})
+
+	c, err := crapi.NewCaproverInstance(srv.URL, srv.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 5 {
+		instances := i + 2
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := workspace.Apply(&c, workspace.AppConfig{AppName: "api", Instances: &instances}); err != nil {
+				t.Errorf("Apply: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := c.UpdateContainerHTTPPort("api", 3000+i); err != nil {
+				t.Errorf("UpdateContainerHTTPPort: %v", err)
+			}
+		}()
+		wg.Wait()
+
+		if app, _ := srv.App("api"); app.InstanceCount != instances || app.ContainerHTTPPort != 3000+i {
+			t.Fatalf("an update was undone: %d instances, port %d", app.InstanceCount, app.ContainerHTTPPort)
+		}
+	}
+}
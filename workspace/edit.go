@@ -0,0 +1,76 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetValue sets a single setting of a configuration file and leaves the rest
+// of the file, comments included, as it is. path names the nested keys, e.g.
+// "Resources", "Limits", "MemoryBytes"; missing keys are added.
+func SetValue(configFile string, value any, path ...string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("no setting to update in %s", configFile)
+	}
+
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("error reading configuration file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error parsing %s: %w", configFile, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	node := doc.Content[0]
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("error updating %s: %s is not a mapping", configFile, key)
+		}
+
+		child := lookupKey(node, key)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		}
+		node = child
+	}
+
+	var encoded yaml.Node
+	if err := encoded.Encode(value); err != nil {
+		return fmt.Errorf("error encoding %v: %w", value, err)
+	}
+	encoded.HeadComment = node.HeadComment
+	encoded.LineComment = node.LineComment
+	encoded.FootComment = node.FootComment
+	*node = encoded
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %w", configFile, err)
+	}
+
+	return os.WriteFile(configFile, out, info.Mode().Perm())
+}
+
+// lookupKey returns the value of key in a mapping node, or nil.
+func lookupKey(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+
+	return nil
+}
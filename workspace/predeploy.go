@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// preDeploySignature matches the declaration of the function CapRover calls
+// before deploying, capturing its parameters.
+var preDeploySignature = regexp.MustCompile(`\b(?:(?:var|let|const)\s+preDeployFunction\s*=\s*(?:async\s+)?(?:function\b[^(]*\(([^)]*)\)|\(([^)]*)\)\s*=>)|function\s+preDeployFunction\s*\(([^)]*)\))`)
+
+// ValidatePreDeploy checks the syntax of a pre-deploy script as far as it can
+// without running it: brackets, strings and comments must be closed, and the
+// script must declare preDeployFunction taking captainAppObj and
+// dockerUpdateObject. Regular expression literals aren't recognized, brackets
+// inside them count.
+func ValidatePreDeploy(script string) error {
+	code, err := stripJS(script)
+	if err != nil {
+		return err
+	}
+
+	m := preDeploySignature.FindStringSubmatch(code)
+	if m == nil {
+		return errors.New("preDeployFunction is not declared, expected var preDeployFunction = function (captainAppObj, dockerUpdateObject) {...}")
+	}
+
+	params := strings.TrimSpace(m[1] + m[2] + m[3])
+	if n := len(strings.Split(params, ",")); params == "" || n != 2 {
+		return fmt.Errorf("preDeployFunction must take 2 parameters (captainAppObj, dockerUpdateObject), got (%s)", params)
+	}
+
+	return nil
+}
+
+// stripJS checks that the brackets, strings and comments of a script are
+// balanced and returns the script with its comments and the content of its
+// strings blanked out.
+func stripJS(script string) (string, error) {
+	type open struct {
+		char rune
+		line int
+	}
+
+	var (
+		out   strings.Builder
+		stack []open
+		line  = 1
+		quote rune // the delimiter of the current string, 0 outside strings
+		start int  // the line the current string or comment started at
+	)
+
+	closing := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		if c == '\n' {
+			line++
+		}
+
+		switch {
+		case quote != 0:
+			switch {
+			case c == '\\':
+				i++
+				if next == '\n' {
+					line++
+				}
+			case c == quote:
+				quote = 0
+				out.WriteRune(c)
+			case c == '\n' && quote != '`':
+				return "", fmt.Errorf("unterminated string at line %d", start)
+			case quote == '`' && c == '$' && next == '{':
+				// the expression of a template literal is code again
+				stack = append(stack, open{'`', line})
+				quote = 0
+				i++
+				out.WriteString("${")
+			case c == '\n':
+				out.WriteRune(c)
+			}
+
+		case c == '/' && next == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			if i < len(runes) {
+				line++
+				out.WriteRune('\n')
+			}
+
+		case c == '/' && next == '*':
+			start = line
+			for i += 2; i+1 < len(runes) && (runes[i] != '*' || runes[i+1] != '/'); i++ {
+				if runes[i] == '\n' {
+					line++
+					out.WriteRune('\n')
+				}
+			}
+			if i+1 >= len(runes) {
+				return "", fmt.Errorf("unterminated comment at line %d", start)
+			}
+			i++
+
+		case c == '"' || c == '\'' || c == '`':
+			quote, start = c, line
+			out.WriteRune(c)
+
+		case c == '(' || c == '[' || c == '{':
+			stack = append(stack, open{c, line})
+			out.WriteRune(c)
+
+		case c == ')' || c == ']' || c == '}':
+			if len(stack) == 0 {
+				return "", fmt.Errorf("unexpected '%c' at line %d", c, line)
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if top.char == '`' && c == '}' {
+				// back in the template literal
+				quote = '`'
+				out.WriteRune(c)
+				continue
+			}
+			if top.char != closing[c] {
+				return "", fmt.Errorf("unexpected '%c' at line %d, '%c' opened at line %d isn't closed", c, line, top.char, top.line)
+			}
+			out.WriteRune(c)
+
+		default:
+			out.WriteRune(c)
+		}
+	}
+
+	if quote != 0 {
+		return "", fmt.Errorf("unterminated string at line %d", start)
+	}
+	if len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if top.char == '`' {
+			return "", fmt.Errorf("unterminated template expression at line %d", top.line)
+		}
+		return "", fmt.Errorf("'%c' opened at line %d isn't closed", top.char, top.line)
+	}
+
+	return out.String(), nil
+}
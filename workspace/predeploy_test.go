@@ -0,0 +1,73 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePreDeploy(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{
+			name: "caprover sample",
+			script: `var preDeployFunction = function (captainAppObj, dockerUpdateObject) {
+    return Promise.resolve().then(function () {
+        // don't count } in comments
+        dockerUpdateObject.TaskTemplate.ContainerSpec.Env.push("STARTED='yes'");
+        return dockerUpdateObject;
+    });
+};`,
+		},
+		{
+			name:   "arrow function with template literal",
+			script: "const preDeployFunction = async (app, update) => {\n  console.log(`deploying ${app.appName} {`);\n  return update;\n};\n",
+		},
+		{
+			name:   "function declaration",
+			script: "/* multi\n line */\nfunction preDeployFunction(a, b) { return b; }\n",
+		},
+		{
+			name:   "missing brace",
+			script: "var preDeployFunction = function (a, b) {\n  if (a) {\n    return b;\n};\n",
+			err:    "'{' opened at line 1 isn't closed",
+		},
+		{
+			name:   "mismatched bracket",
+			script: "var preDeployFunction = function (a, b) {\n  return [b);\n};\n",
+			err:    "unexpected ')' at line 2",
+		},
+		{
+			name:   "unterminated string",
+			script: "var preDeployFunction = function (a, b) {\n  var s = 'oops;\n  return b;\n};\n",
+			err:    "unterminated string at line 2",
+		},
+		{
+			name:   "wrong name",
+			script: "var preDeploy = function (a, b) { return b; };\n",
+			err:    "preDeployFunction is not declared",
+		},
+		{
+			name:   "name only in a string",
+			script: "var s = 'var preDeployFunction = function (a, b) {}';\n",
+			err:    "preDeployFunction is not declared",
+		},
+		{
+			name:   "wrong parameters",
+			script: "var preDeployFunction = function (update) { return update; };\n",
+			err:    "must take 2 parameters",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePreDeploy(tt.script)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
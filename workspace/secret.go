@@ -0,0 +1,70 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Redacted stands for a secret that is set on CapRover but isn't kept in the
+// workspace. Exported configurations use it, and applying it keeps the
+// current value.
+const Redacted = "<redacted>"
+
+// SecretRef points to a secret instead of holding it, so configuration files
+// can be committed. It is one of:
+//
+//	env:NAME    the value of the environment variable NAME
+//	file:PATH   the content of a file, relative to the configuration file
+//	<redacted>  whatever CapRover currently has
+//
+// An empty SecretRef means no secret.
+type SecretRef string
+
+// IsRedacted reports whether the secret keeps its current value.
+func (s SecretRef) IsRedacted() bool {
+	return s == Redacted
+}
+
+// Resolve returns the secret. Relative file paths are resolved against dir.
+func (s SecretRef) Resolve(dir string) (string, error) {
+	ref := string(s)
+	switch {
+	case ref == "":
+		return "", nil
+	case s.IsRedacted():
+		return "", fmt.Errorf("a redacted secret has no value")
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "file:"):
+		path := expandPath(strings.TrimPrefix(ref, "file:"), dir)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading secret: %w", err)
+		}
+		return string(data), nil
+	}
+
+	return "", fmt.Errorf("secrets can't be written inline, use env:NAME or file:PATH")
+}
+
+// expandPath expands a leading ~ and makes relative paths relative to dir.
+func expandPath(path string, dir string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	return path
+}
@@ -0,0 +1,84 @@
+package workspace
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Selector selects apps by their labels. It is a comma separated list of
+// requirements that must all be met:
+//
+//	key=value, key==value  the label is set to value
+//	key!=value             the label isn't set to value, or isn't set
+//	key                    the label is set
+//	!key                   the label isn't set
+type Selector []requirement
+
+type requirement struct {
+	key   string
+	op    string
+	value string
+}
+
+// ParseSelector parses a selector. An empty string selects every app.
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var r requirement
+		switch {
+		case strings.Contains(part, "!="):
+			r.key, r.value, _ = strings.Cut(part, "!=")
+			r.op = "!="
+		case strings.Contains(part, "=="):
+			r.key, r.value, _ = strings.Cut(part, "==")
+			r.op = "="
+		case strings.Contains(part, "="):
+			r.key, r.value, _ = strings.Cut(part, "=")
+			r.op = "="
+		case strings.HasPrefix(part, "!"):
+			r.key, r.op = strings.TrimPrefix(part, "!"), "!"
+		default:
+			r.key, r.op = part, ""
+		}
+
+		r.key, r.value = strings.TrimSpace(r.key), strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid selector %q: missing label key", part)
+		}
+		sel = append(sel, r)
+	}
+
+	return sel, nil
+}
+
+// Matches reports whether labels meet every requirement of the selector.
+func (sel Selector) Matches(labels map[string]string) bool {
+	for _, r := range sel {
+		value, ok := labels[r.key]
+		switch r.op {
+		case "=":
+			if !ok || value != r.value {
+				return false
+			}
+		case "!=":
+			if ok && value == r.value {
+				return false
+			}
+		case "!":
+			if ok {
+				return false
+			}
+		default:
+			if !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
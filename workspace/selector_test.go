@@ -0,0 +1,40 @@
+package workspace
+
+import "testing"
+
+func TestSelector(t *testing.T) {
+	labels := map[string]string{"team": "payments", "tier": "1", "beta": ""}
+
+	for _, tt := range []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"team=payments", true},
+		{"team==payments", true},
+		{"team=web", false},
+		{"team=payments,tier!=2", true},
+		{"team=payments, tier!=1", false},
+		{"owner!=bob", true},
+		{"beta", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!beta", false},
+		{"beta=", true},
+	} {
+		sel, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("ParseSelector(%q): %v", tt.selector, err)
+			continue
+		}
+		if got := sel.Matches(labels); got != tt.want {
+			t.Errorf("%q matches %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"=payments", "!", "!=x"} {
+		if _, err := ParseSelector(invalid); err == nil {
+			t.Errorf("ParseSelector(%q) should fail", invalid)
+		}
+	}
+}
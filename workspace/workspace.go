@@ -0,0 +1,326 @@
+// Package workspace holds the declarative app configuration used by letgofur
+// workspaces and the logic to export it from and apply it to CapRover.
+//
+// It can be imported by other Go programs that want to manage CapRover apps
+// the same way the letgofur commands do.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pararang/letgofur/crapi"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrServiceUpdateOverride is returned by Export when the ServiceUpdateOverride
+// of an app can't be parsed.
+var ErrServiceUpdateOverride = errors.New("error parsing ServiceUpdateOverride")
+
+// AppConfig represents the configuration for an app
+type AppConfig struct {
+	AppName string `yaml:"AppName"`
+	// Project is the path of the project the app belongs to, e.g.
+	// "shop/payments" for a nested project, or empty for none. Unset keeps
+	// the current project.
+	Project *string `yaml:"Project,omitempty"`
+	// Labels are stored in the tags of the app as "key=value", on CapRover
+	// versions with tags, and select apps with a Selector. Unset keeps the
+	// current labels.
+	Labels map[string]string `yaml:"Labels,omitempty"`
+	// Instances is the number of instances of the app, 0 stopping it. Unset
+	// keeps the current number.
+	Instances *int      `yaml:"Instances,omitempty"`
+	Resources Resources `yaml:"Resources"`
+
+	// Unset settings keep their current value.
+	Description                       *string `yaml:"Description,omitempty"`
+	NotExposeAsWebApp                 *bool   `yaml:"NotExposeAsWebApp,omitempty"`
+	ContainerHTTPPort                 int     `yaml:"ContainerHTTPPort,omitempty"`
+	WebsocketSupport                  *bool   `yaml:"WebsocketSupport,omitempty"`
+	ForceSsl                          *bool   `yaml:"ForceSsl,omitempty"`
+	RedirectDomain                    *string `yaml:"RedirectDomain,omitempty"`
+	CaptainDefinitionRelativeFilePath string  `yaml:"CaptainDefinitionRelativeFilePath,omitempty"`
+
+	// Nginx is the path of the app's custom NGINX template, relative to the
+	// configuration file, or NginxDefault for the template of the instance.
+	Nginx string `yaml:"Nginx,omitempty"`
+
+	// PreDeploy is the path of the app's pre-deploy script, relative to the
+	// configuration file. See ValidatePreDeploy.
+	PreDeploy string `yaml:"PreDeploy,omitempty"`
+
+	Git *GitConfig `yaml:"Git,omitempty"`
+
+	HTTPAuth *HTTPAuthConfig `yaml:"HTTPAuth,omitempty"`
+
+	// Node pins the app to a node of the cluster, by ID or hostname. NodeNone
+	// unpins it, unset keeps the current node.
+	Node string `yaml:"Node,omitempty"`
+	// Networks are the Docker networks the app is attached to. They are
+	// exported for reference: CapRover's API can't change them.
+	Networks []string `yaml:"Networks,omitempty"`
+
+	// Dir is the directory relative paths in the configuration are resolved
+	// against. Load sets it to the directory of the configuration file.
+	Dir string `yaml:"-"`
+}
+
+// GitConfig is the git repository an app is built from. Credentials are
+// secret references, never the secrets themselves. Leaving the whole section
+// out keeps the app's current repository settings.
+type GitConfig struct {
+	Repo     string    `yaml:"Repo"`
+	Branch   string    `yaml:"Branch"`
+	User     string    `yaml:"User,omitempty"`
+	Password SecretRef `yaml:"Password,omitempty"`
+	SSHKey   SecretRef `yaml:"SSHKey,omitempty"`
+}
+
+// HTTPAuthConfig is the HTTP basic auth protecting an app. The password is a
+// secret reference. An empty User removes the auth, leaving the whole section
+// out keeps the app's current one.
+//
+// CapRover only keeps a hash of the password, so a changed password can't be
+// detected: it is sent when the auth is added or the user changes. Use
+// "letgofur auth set" to change the password alone.
+type HTTPAuthConfig struct {
+	User     string    `yaml:"User"`
+	Password SecretRef `yaml:"Password,omitempty"`
+}
+
+type Resources struct {
+	Limits       Resource `yaml:"Limits"`
+	Reservations Resource `yaml:"Reservations"`
+}
+
+type Resource struct {
+	MemoryBytes *int64 `yaml:"MemoryBytes"`
+	NanoCPUs    *int64 `yaml:"NanoCPUs"`
+}
+
+type TaskTemplate struct {
+	Resources Resources `yaml:"Resources"`
+}
+
+// ServiceUpdateOverride represents the structure of the ServiceUpdateOverride field
+type ServiceUpdateOverride struct {
+	TaskTemplate TaskTemplate `yaml:"TaskTemplate"`
+}
+
+// Export builds the configuration of an existing app. When the app's
+// ServiceUpdateOverride can't be parsed, the returned config has no resources
+// and the error says why; the config is still usable. The same goes for tags
+// holding the same label key more than once: the config has no labels, so
+// applying it keeps the tags as they are.
+//
+// Secrets are never exported, they are replaced by Redacted.
+func Export(app crapi.AppDefinition) (AppConfig, error) {
+	config := AppConfig{
+		AppName:                           app.AppName,
+		Instances:                         &app.InstanceCount,
+		NotExposeAsWebApp:                 &app.NotExposeAsWebApp,
+		ContainerHTTPPort:                 app.ContainerHTTPPort,
+		WebsocketSupport:                  &app.WebsocketSupport,
+		ForceSsl:                          &app.ForceSsl,
+		CaptainDefinitionRelativeFilePath: app.CaptainDefinitionRelativeFilePath,
+		Node:                              app.NodeID,
+		Networks:                          app.Networks,
+		Labels:                            crapi.Labels(app.Tags),
+	}
+	var err error
+	if keys := crapi.DuplicateLabels(app.Tags); len(keys) > 0 {
+		config.Labels = nil
+		err = fmt.Errorf("labels of app '%s' not exported, %s set more than once", app.AppName, strings.Join(keys, ", "))
+	}
+	// empty strings are left out, they would only add noise to every file
+	if app.Description != "" {
+		config.Description = &app.Description
+	}
+	if app.RedirectDomain != "" {
+		config.RedirectDomain = &app.RedirectDomain
+	}
+
+	// Extract resource limits if available
+	if app.ServiceUpdateOverride != "" {
+		// The ServiceUpdateOverride is a YAML string
+		var suo ServiceUpdateOverride
+		if parseErr := yaml.Unmarshal([]byte(app.ServiceUpdateOverride), &suo); parseErr != nil {
+			return config, errors.Join(err, fmt.Errorf("%w for app '%s': %w", ErrServiceUpdateOverride, app.AppName, parseErr))
+		}
+		config.Resources = suo.TaskTemplate.Resources
+	}
+
+	config.Git = exportGit(app.AppPushWebhook.RepoInfo)
+	if app.HTTPAuth != nil && app.HTTPAuth.User != "" {
+		config.HTTPAuth = &HTTPAuthConfig{User: app.HTTPAuth.User, Password: Redacted}
+	}
+
+	return config, err
+}
+
+// File is a file referenced by a configuration, e.g. a NGINX template. Path
+// is relative to the configuration file.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// ExportFiles exports an app like Export, and also the settings kept in
+// files of their own next to the configuration: the pre-deploy script and the
+// custom NGINX template. The template is only exported when it differs from
+// defaultNginxConfig, the template of the instance.
+func ExportFiles(app crapi.AppDefinition, defaultNginxConfig string) (AppConfig, []File, error) {
+	config, err := Export(app)
+
+	var files []File
+	if nginx := app.CustomNginxConfig; strings.TrimSpace(nginx) != "" && nginx != defaultNginxConfig {
+		config.Nginx = "./nginx/" + app.AppName + ".conf"
+		files = append(files, File{Path: config.Nginx, Content: []byte(nginx)})
+	}
+	if script := app.PreDeployFunction; strings.TrimSpace(script) != "" {
+		config.PreDeploy = "./predeploy/" + app.AppName + ".js"
+		files = append(files, File{Path: config.PreDeploy, Content: []byte(script)})
+	}
+
+	return config, files, err
+}
+
+// Load reads and validates a configuration file.
+func Load(configFile string) (AppConfig, error) {
+	// Check if file exists
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		return AppConfig{}, fmt.Errorf("configuration file not found: %s", configFile)
+	}
+
+	// Read the configuration file
+	yamlData, err := os.ReadFile(configFile)
+	if err != nil {
+		return AppConfig{}, fmt.Errorf("error reading configuration file: %w", err)
+	}
+
+	// Parse the YAML configuration
+	var config AppConfig
+	if err := yaml.Unmarshal(yamlData, &config); err != nil {
+		return AppConfig{}, fmt.Errorf("error parsing YAML configuration: %w", err)
+	}
+
+	// Validate the configuration
+	if config.AppName == "" {
+		return AppConfig{}, fmt.Errorf("invalid configuration: AppName is required")
+	}
+	config.Dir = filepath.Dir(configFile)
+
+	return config, nil
+}
+
+// Validate checks the files referenced by a configuration without connecting
+// to CapRover: they must be readable, and the pre-deploy script must pass
+// ValidatePreDeploy.
+func Validate(config AppConfig) error {
+	var errs []error
+
+	if config.Nginx != "" {
+		if _, err := readNginx(config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if config.PreDeploy != "" {
+		if _, err := readPreDeploy(config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Save writes a configuration file.
+func Save(configFile string, config AppConfig) error {
+	yamlData, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("error marshaling config for app '%s': %w", config.AppName, err)
+	}
+
+	if err := os.WriteFile(configFile, yamlData, 0644); err != nil {
+		return fmt.Errorf("error writing config for app '%s': %w", config.AppName, err)
+	}
+
+	return nil
+}
+
+func exportGit(repo crapi.AppRepoInfo) *GitConfig {
+	if repo.Repo == "" {
+		return nil
+	}
+
+	git := &GitConfig{
+		Repo:   repo.Repo,
+		Branch: repo.Branch,
+		User:   repo.User,
+	}
+	if repo.Password != "" {
+		git.Password = Redacted
+	}
+	if repo.SSHKey != "" {
+		git.SSHKey = Redacted
+	}
+
+	return git
+}
+
+// SaveFiles writes the files referenced by a configuration file.
+func SaveFiles(configFile string, files []File) error {
+	for _, f := range files {
+		path := filepath.Join(filepath.Dir(configFile), filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("error creating directory for %s: %w", f.Path, err)
+		}
+		if err := os.WriteFile(path, f.Content, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", f.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// readNginx reads the NGINX template of a configuration, empty for
+// NginxDefault.
+func readNginx(config AppConfig) (string, error) {
+	if config.Nginx == NginxDefault {
+		return "", nil
+	}
+
+	nginx, err := readFile(config, config.Nginx)
+	if err != nil {
+		return "", fmt.Errorf("error reading NGINX template: %w", err)
+	}
+
+	return nginx, nil
+}
+
+// readPreDeploy reads and validates the pre-deploy script of a configuration.
+func readPreDeploy(config AppConfig) (string, error) {
+	script, err := readFile(config, config.PreDeploy)
+	if err != nil {
+		return "", fmt.Errorf("error reading pre-deploy script: %w", err)
+	}
+	if err := ValidatePreDeploy(script); err != nil {
+		return "", fmt.Errorf("invalid pre-deploy script %s: %w", config.PreDeploy, err)
+	}
+
+	return script, nil
+}
+
+// readFile reads a file referenced by a configuration.
+func readFile(config AppConfig, path string) (string, error) {
+	data, err := os.ReadFile(expandPath(path, config.Dir))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}